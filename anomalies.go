@@ -0,0 +1,57 @@
+package main
+
+import (
+  "maps"
+  "slices"
+  "strings"
+)
+
+// findPreviousIndex returns the newest index filed before `filingDate`.
+func findPreviousIndex(indexes []Index, filingDate string) (Index, bool) {
+  found := false
+  previous := Index{}
+  for _, index := range indexes {
+    if strings.Compare(index.FilingDate, filingDate) >= 0 {
+      continue
+    }
+    if !found || strings.Compare(index.FilingDate, previous.FilingDate) > 0 {
+      previous = index
+      found = true
+    }
+  }
+  return previous, found
+}
+
+func totalWeight(index Index) float64 {
+  total := 0.0
+  for _, component := range index.Components {
+    total += float64(component.Weight)
+  }
+  return total
+}
+
+func idTypeShares(index Index) map[string]float64 {
+  shares := map[string]float64{}
+  for _, component := range index.Components {
+    shares[component.IdType] += 1 / float64(len(index.Components))
+  }
+  return shares
+}
+
+// validateAgainstPrevious runs the cross-filing rules of kValidationRules between `previous` and `index`.
+func validateAgainstPrevious(res *ValidationResult, previous Index, index Index) {
+  ctx := ruleContext{res.cik, res.etfName, true, index, previous}
+  runRules(res, kCrossFilingStage, ctx)
+}
+
+// sortedKeys returns the keys present in any of `a` or `b`, sorted.
+func sortedKeys(a, b map[string]float64) []string {
+  keys := slices.Collect(maps.Keys(a))
+  for k := range b {
+    if _, ok := a[k]; !ok {
+      keys = append(keys, k)
+    }
+  }
+  slices.Sort(keys)
+  return keys
+}
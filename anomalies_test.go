@@ -0,0 +1,81 @@
+package main
+
+import (
+  "fmt"
+  "testing"
+)
+
+// generateIndex returns an index with `count` ISIN components of weight `weight`, sorted by decreasing weight.
+func generateIndex(name string, filingDate string, count int, weight float32) Index {
+  index := Index{Name: name, SeriesId: kValidSeriesId, FilingDate: filingDate, Components: []IndexComponent{}}
+  for i := 0; i < count; i++ {
+    index.Components = append(index.Components, IndexComponent{Name: fmt.Sprintf("Company %d", i), Id: fmt.Sprintf("ID%d", i), IdType: "isin", Weight: weight})
+  }
+  return index
+}
+
+func TestValidateAgainstPrevious(t *testing.T) {
+  previous := generateIndex("Index", "2025-01-01", 100, 1)
+  withSedols := generateIndex("Index", "2025-04-01", 100, 1)
+  for i := 50; i < 100; i++ {
+    withSedols.Components[i].IdType = "sedol"
+  }
+  withoutTopHolding := generateIndex("Index", "2025-04-01", 100, 1)
+  withoutTopHolding.Components[0].Id = "NEW"
+
+  tt := []struct {
+    name string
+    index Index
+    expectedWarnings int
+  } {
+    {"Similar filing", generateIndex("Index", "2025-04-01", 105, 0.96), 0},
+    {"Series name changed", generateIndex("Other Index", "2025-04-01", 100, 1), 1},
+    {"Component count swing", generateIndex("Index", "2025-04-01", 150, 0.66), 1},
+    {"Total weight moved", generateIndex("Index", "2025-04-01", 100, 0.9), 1},
+    {"Top holding disappeared", withoutTopHolding, 1},
+    // Both the isin and sedol shares changed.
+    {"Identifier type mix changed", withSedols, 2},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      res := ValidationResult{etfName: "VXF", warnings: []string{}, errors: []string{}}
+      validateAgainstPrevious(&res, previous, tc.index)
+      if len(res.warnings) != tc.expectedWarnings {
+        t.Errorf("Expected %d warnings but got %+v", tc.expectedWarnings, res.warnings)
+      }
+      if len(res.errors) != 0 {
+        t.Errorf("Expected no errors but got %+v", res.errors)
+      }
+    })
+  }
+}
+
+func TestFindPreviousIndex(t *testing.T) {
+  indexes := []Index{generateIndex("Index", "2025-04-01", 1, 1), generateIndex("Index", "2024-10-01", 1, 1), generateIndex("Index", "2025-01-01", 1, 1)}
+  tt := []struct {
+    name string
+    filingDate string
+    expected string // empty if not found.
+  } {
+    {"Newer filing", "2025-07-01", "2025-04-01"},
+    {"Filing in between", "2025-02-01", "2025-01-01"},
+    {"Same date is not previous", "2025-01-01", "2024-10-01"},
+    {"Oldest filing", "2024-10-01", ""},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      previous, ok := findPreviousIndex(indexes, tc.filingDate)
+      if tc.expected == "" {
+        if ok {
+          t.Errorf("Expected no previous index but got %s", previous.FilingDate)
+        }
+        return
+      }
+      if !ok || previous.FilingDate != tc.expected {
+        t.Errorf("Expected previous index %s but got %s (found=%t)", tc.expected, previous.FilingDate, ok)
+      }
+    })
+  }
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+  "strings"
+  "time"
+)
+
+// Subset of the `<debtSec>` block of the N-PORT specification:
+// https://www.sec.gov/info/edgar/specifications/form-n-port-xml-tech-specs.htm
+//
+// Numbers are kept as strings as the filings can contain "N/A", which would
+// make the whole document fail to parse.
+type debtSec struct {
+  MaturityDt string `xml:"maturityDt"`
+  // One of "Fixed", "Floating", "Variable" or "None".
+  CouponKind string `xml:"couponKind"`
+  AnnualizedRt string `xml:"annualizedRt"`
+  IsDefault string `xml:"isDefault"`
+  AreIntrstPmntsInArrs string `xml:"areIntrstPmntsInArrs"`
+  IsPaidKind string `xml:"isPaidKind"`
+  // The fields below are only present for convertible securities.
+  IsMandatoryConvrtbl string `xml:"isMandatoryConvrtbl"`
+  IsContngtConvrtbl string `xml:"isContngtConvrtbl"`
+  DbtSecRefInstruments struct {
+    DbtSecRefInstrument []struct {
+      Name string `xml:"name"`
+      Title string `xml:"title"`
+      CurrencyInfos struct {
+        CurrencyInfo []struct {
+          ConvRatio string `xml:"convRatio,attr"`
+          CurCd string `xml:"curCd,attr"`
+        } `xml:"currencyInfo"`
+      } `xml:"currencyInfos"`
+    } `xml:"dbtSecRefInstrument"`
+  } `xml:"dbtSecRefInstruments"`
+}
+
+// getDebtInfo returns nil if the component isn't a debt security.
+func getDebtInfo(d debtSec) *DebtInfo {
+  if d.MaturityDt == "" && d.CouponKind == "" {
+    return nil
+  }
+  info := &DebtInfo{
+    MaturityDate: d.MaturityDt,
+    CouponKind: strings.ToLower(d.CouponKind),
+    CouponRate: parseFloat32(d.AnnualizedRt),
+    IsDefault: parseYesNo(d.IsDefault),
+    InterestInArrears: parseYesNo(d.AreIntrstPmntsInArrs),
+    IsPaidInKind: parseYesNo(d.IsPaidKind),
+  }
+  if d.IsMandatoryConvrtbl == "" && d.IsContngtConvrtbl == "" {
+    return info
+  }
+
+  convertible := &ConvertibleInfo{IsMandatory: parseYesNo(d.IsMandatoryConvrtbl), IsContingent: parseYesNo(d.IsContngtConvrtbl), References: []ConvertibleReference{}}
+  for _, ref := range d.DbtSecRefInstruments.DbtSecRefInstrument {
+    for _, currency := range ref.CurrencyInfos.CurrencyInfo {
+      convertible.References = append(convertible.References, ConvertibleReference{Name: ref.Name, Title: ref.Title, ConversionRatio: parseFloat32(currency.ConvRatio), Currency: currency.CurCd})
+    }
+  }
+  info.Convertible = convertible
+  return info
+}
+
+// Upper bounds (exclusive) of the maturity buckets, in years.
+var kMaturityBucketBounds = []int{1, 3, 5, 7, 10, 20, 30}
+
+func yearsBetween(from, to time.Time) float64 {
+  return to.Sub(from).Hours() / 24 / 365.25
+}
+
+// computeBondAnalytics returns nil if the index doesn't have any bond.
+// The maturities are measured from the end of the reporting period, or from
+// the filing date for the indexes without one (filed up to 60 days later).
+// Bonds with an unparseable maturity date are ignored for the maturity
+// computation, but still count towards the weighted coupon.
+func computeBondAnalytics(index Index) *BondAnalytics {
+  asOf := index.ReportDate
+  if asOf == "" {
+    asOf = index.FilingDate
+  }
+  asOfDate, err := time.Parse(time.DateOnly, asOf)
+  if err != nil {
+    return nil
+  }
+
+  buckets := []MaturityBucket{}
+  lowerBound := 0
+  for _, upperBound := range kMaturityBucketBounds {
+    buckets = append(buckets, MaturityBucket{MinYears: lowerBound, MaxYears: upperBound, Weight: 0})
+    lowerBound = upperBound
+  }
+  buckets = append(buckets, MaturityBucket{MinYears: lowerBound, MaxYears: 0, Weight: 0})
+
+  hasBonds := false
+  var bondWeight, couponSum, maturityWeight, maturitySum float64
+  for _, component := range index.Components {
+    if component.Debt == nil {
+      continue
+    }
+    hasBonds = true
+    weight := float64(component.Weight)
+    bondWeight += weight
+    couponSum += weight * float64(component.Debt.CouponRate)
+
+    maturityDate, err := time.Parse(time.DateOnly, component.Debt.MaturityDate)
+    if err != nil {
+      continue
+    }
+    // Matured bonds are put in the first bucket.
+    years := max(yearsBetween(asOfDate, maturityDate), 0)
+    maturityWeight += weight
+    maturitySum += weight * years
+    for i := range buckets {
+      if buckets[i].MaxYears == 0 || years < float64(buckets[i].MaxYears) {
+        buckets[i].Weight += component.Weight
+        break
+      }
+    }
+  }
+  if !hasBonds {
+    return nil
+  }
+
+  analytics := &BondAnalytics{BondWeight: float32(bondWeight), MaturityBuckets: buckets}
+  if bondWeight > 0 {
+    analytics.WeightedCoupon = float32(couponSum / bondWeight)
+  }
+  if maturityWeight > 0 {
+    analytics.WeightedAverageMaturity = float32(maturitySum / maturityWeight)
+  }
+  return analytics
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "math"
+  "testing"
+)
+
+func parseSingleInvstOrSec(invstOrSecXml string) invstOrSec {
+  payload := fmt.Sprintf(`<invstOrSecs>%s</invstOrSecs>`, invstOrSecXml)
+  v := struct {
+    InvstOrSec []invstOrSec `xml:"invstOrSec"`
+  }{}
+  if err := xml.Unmarshal([]byte(payload), &v); err != nil || len(v.InvstOrSec) != 1 {
+    panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", payload, err))
+  }
+  return v.InvstOrSec[0]
+}
+
+func TestDebtInfo(t *testing.T) {
+  tt := []struct {
+    name string
+    invstOrSecXml string
+    expected *DebtInfo
+  } {
+    {"Equity has no debt information", `<invstOrSec><name>Warby Parker Inc</name><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.003502379516</pctVal></invstOrSec>`, nil},
+    {"Treasury strip", `<invstOrSec><name>United States Treasury Strip Coupon</name><identifiers><isin value="US912834PZ59"/></identifiers><pctVal>2.021988</pctVal><debtSec><maturityDt>2050-05-15</maturityDt><couponKind>None</couponKind><annualizedRt>0</annualizedRt><isDefault>N</isDefault><areIntrstPmntsInArrs>N</areIntrstPmntsInArrs><isPaidKind>N</isPaidKind></debtSec></invstOrSec>`, &DebtInfo{MaturityDate: "2050-05-15", CouponKind: "none", CouponRate: 0, IsDefault: false, InterestInArrears: false, IsPaidInKind: false, Convertible: nil}},
+    {"Corporate bond in default", `<invstOrSec><name>CVS Health Corp</name><identifiers><isin value="US126650CZ11"/></identifiers><pctVal>0.16</pctVal><debtSec><maturityDt>2048-03-25</maturityDt><couponKind>Fixed</couponKind><annualizedRt>5.05</annualizedRt><isDefault>Y</isDefault><areIntrstPmntsInArrs>Y</areIntrstPmntsInArrs><isPaidKind>N</isPaidKind></debtSec></invstOrSec>`, &DebtInfo{MaturityDate: "2048-03-25", CouponKind: "fixed", CouponRate: 5.05, IsDefault: true, InterestInArrears: true, IsPaidInKind: false, Convertible: nil}},
+    {"Unparseable rate", `<invstOrSec><name>Bond</name><identifiers><isin value="US126650CZ11"/></identifiers><pctVal>0.16</pctVal><debtSec><maturityDt>2048-03-25</maturityDt><couponKind>Floating</couponKind><annualizedRt>N/A</annualizedRt><isDefault>N</isDefault><areIntrstPmntsInArrs>N</areIntrstPmntsInArrs><isPaidKind>Y</isPaidKind></debtSec></invstOrSec>`, &DebtInfo{MaturityDate: "2048-03-25", CouponKind: "floating", CouponRate: 0, IsDefault: false, InterestInArrears: false, IsPaidInKind: true, Convertible: nil}},
+    {"Convertible bond", `<invstOrSec><name>Convertible</name><identifiers><isin value="US126650CZ11"/></identifiers><pctVal>0.16</pctVal><debtSec><maturityDt>2030-01-15</maturityDt><couponKind>Fixed</couponKind><annualizedRt>1.5</annualizedRt><isDefault>N</isDefault><areIntrstPmntsInArrs>N</areIntrstPmntsInArrs><isPaidKind>N</isPaidKind><isMandatoryConvrtbl>N</isMandatoryConvrtbl><isContngtConvrtbl>Y</isContngtConvrtbl><dbtSecRefInstruments><dbtSecRefInstrument><name>Company Inc</name><title>Common Stock</title><currencyInfos><currencyInfo convRatio="12.5" curCd="USD"/></currencyInfos></dbtSecRefInstrument></dbtSecRefInstruments></debtSec></invstOrSec>`, &DebtInfo{MaturityDate: "2030-01-15", CouponKind: "fixed", CouponRate: 1.5, IsDefault: false, InterestInArrears: false, IsPaidInKind: false, Convertible: &ConvertibleInfo{IsMandatory: false, IsContingent: true, References: []ConvertibleReference{ConvertibleReference{Name: "Company Inc", Title: "Common Stock", ConversionRatio: 12.5, Currency: "USD"}}}}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      info := getDebtInfo(parseSingleInvstOrSec(tc.invstOrSecXml).DebtSec)
+      if tc.expected == nil {
+        if info != nil {
+          t.Errorf("Expected no debt information but got %+v", info)
+        }
+        return
+      }
+      if info == nil {
+        t.Errorf("Expected debt information %+v but got none", tc.expected)
+        return
+      }
+      if (tc.expected.Convertible == nil) != (info.Convertible == nil) {
+        t.Errorf("Mismatched convertible information, expected=%+v but got=%+v", tc.expected.Convertible, info.Convertible)
+        return
+      }
+      if tc.expected.Convertible != nil && fmt.Sprintf("%+v", *info.Convertible) != fmt.Sprintf("%+v", *tc.expected.Convertible) {
+        t.Errorf("Mismatched convertible information, expected=%+v but got=%+v", tc.expected.Convertible, info.Convertible)
+        return
+      }
+      // The convertible information was compared above.
+      expected, got := *tc.expected, *info
+      expected.Convertible, got.Convertible = nil, nil
+      if expected != got {
+        t.Errorf("Mismatched debt information, expected=%+v but got=%+v", expected, got)
+        return
+      }
+    })
+  }
+}
+
+func bond(weight float32, maturityDate string, couponRate float32) IndexComponent {
+  return IndexComponent{Name: "Bond", Id: "US912834PZ59", IdType: "isin", Weight: weight, Debt: &DebtInfo{MaturityDate: maturityDate, CouponKind: "fixed", CouponRate: couponRate}}
+}
+
+func almostEqual(a, b float32) bool {
+  return math.Abs(float64(a - b)) < 1e-4
+}
+
+func TestBondAnalytics(t *testing.T) {
+  tt := []struct {
+    name string
+    components []IndexComponent
+    // nil if no analytics are expected.
+    expected *BondAnalytics
+  } {
+    {"No bonds", []IndexComponent{IndexComponent{Name: "Company", Id: "US93403J1060", IdType: "isin", Weight: 1}}, nil},
+    {"Single bond", []IndexComponent{bond(2, "2030-07-01", 4)}, &BondAnalytics{BondWeight: 2, WeightedAverageMaturity: 5.4949, WeightedCoupon: 4, MaturityBuckets: []MaturityBucket{{MinYears: 0, MaxYears: 1, Weight: 0}, {MinYears: 1, MaxYears: 3, Weight: 0}, {MinYears: 3, MaxYears: 5, Weight: 0}, {MinYears: 5, MaxYears: 7, Weight: 2}, {MinYears: 7, MaxYears: 10, Weight: 0}, {MinYears: 10, MaxYears: 20, Weight: 0}, {MinYears: 20, MaxYears: 30, Weight: 0}, {MinYears: 30, MaxYears: 0, Weight: 0}}}},
+    {"Weighted by the bonds' weights", []IndexComponent{bond(3, "2026-07-01", 2), bond(1, "2055-07-01", 6)}, &BondAnalytics{BondWeight: 4, WeightedAverageMaturity: 8.7447, WeightedCoupon: 3, MaturityBuckets: []MaturityBucket{{MinYears: 0, MaxYears: 1, Weight: 0}, {MinYears: 1, MaxYears: 3, Weight: 3}, {MinYears: 3, MaxYears: 5, Weight: 0}, {MinYears: 5, MaxYears: 7, Weight: 0}, {MinYears: 7, MaxYears: 10, Weight: 0}, {MinYears: 10, MaxYears: 20, Weight: 0}, {MinYears: 20, MaxYears: 30, Weight: 0}, {MinYears: 30, MaxYears: 0, Weight: 1}}}},
+    {"Matured bonds are in the first bucket", []IndexComponent{bond(1, "2020-01-01", 1)}, &BondAnalytics{BondWeight: 1, WeightedAverageMaturity: 0, WeightedCoupon: 1, MaturityBuckets: []MaturityBucket{{MinYears: 0, MaxYears: 1, Weight: 1}, {MinYears: 1, MaxYears: 3, Weight: 0}, {MinYears: 3, MaxYears: 5, Weight: 0}, {MinYears: 5, MaxYears: 7, Weight: 0}, {MinYears: 7, MaxYears: 10, Weight: 0}, {MinYears: 10, MaxYears: 20, Weight: 0}, {MinYears: 20, MaxYears: 30, Weight: 0}, {MinYears: 30, MaxYears: 0, Weight: 0}}}},
+    {"Unparseable maturity is ignored for the maturity", []IndexComponent{bond(1, "2030-07-01", 2), bond(1, "N/A", 4)}, &BondAnalytics{BondWeight: 2, WeightedAverageMaturity: 5.4949, WeightedCoupon: 3, MaturityBuckets: []MaturityBucket{{MinYears: 0, MaxYears: 1, Weight: 0}, {MinYears: 1, MaxYears: 3, Weight: 0}, {MinYears: 3, MaxYears: 5, Weight: 0}, {MinYears: 5, MaxYears: 7, Weight: 1}, {MinYears: 7, MaxYears: 10, Weight: 0}, {MinYears: 10, MaxYears: 20, Weight: 0}, {MinYears: 20, MaxYears: 30, Weight: 0}, {MinYears: 30, MaxYears: 0, Weight: 0}}}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      analytics := computeBondAnalytics(Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-01-01", Components: tc.components})
+      if tc.expected == nil {
+        if analytics != nil {
+          t.Errorf("Expected no analytics but got %+v", analytics)
+        }
+        return
+      }
+      if analytics == nil {
+        t.Errorf("Expected analytics %+v but got none", tc.expected)
+        return
+      }
+      if !almostEqual(tc.expected.BondWeight, analytics.BondWeight) {
+        t.Errorf("Mismatched bond weight, expected=%f but got=%f", tc.expected.BondWeight, analytics.BondWeight)
+      }
+      if !almostEqual(tc.expected.WeightedAverageMaturity, analytics.WeightedAverageMaturity) {
+        t.Errorf("Mismatched weighted average maturity, expected=%f but got=%f", tc.expected.WeightedAverageMaturity, analytics.WeightedAverageMaturity)
+      }
+      if !almostEqual(tc.expected.WeightedCoupon, analytics.WeightedCoupon) {
+        t.Errorf("Mismatched weighted coupon, expected=%f but got=%f", tc.expected.WeightedCoupon, analytics.WeightedCoupon)
+      }
+      if fmt.Sprintf("%+v", tc.expected.MaturityBuckets) != fmt.Sprintf("%+v", analytics.MaturityBuckets) {
+        t.Errorf("Mismatched maturity buckets, expected=%+v but got=%+v", tc.expected.MaturityBuckets, analytics.MaturityBuckets)
+      }
+    })
+  }
+
+  // The maturities are measured from the end of the reporting period when known.
+  analytics := computeBondAnalytics(Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-01-01", ReportDate: "2024-09-30", Components: []IndexComponent{bond(2, "2030-07-01", 4)}})
+  if analytics == nil || !almostEqual(5.7495, analytics.WeightedAverageMaturity) {
+    t.Errorf("Expected a weighted average maturity of 5.7495 from the report date but got %+v", analytics)
+  }
+}
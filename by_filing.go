@@ -0,0 +1,193 @@
+package main
+
+import (
+  "bytes"
+  "errors"
+  "flag"
+  "fmt"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+)
+
+// Name of the per-ETF index file in data/by_filing/<ETF>/.
+const kFilingsIndexFile = "filings.json"
+
+// Whether the fetch also writes the filings one per file, see `-by_filing`.
+var writeByFiling = false
+
+// FilingsIndex lists the filings of an ETF in data/by_filing/<ETF>/, from the newest to the oldest.
+type FilingsIndex struct {
+  SchemaVersion int `json:"schema_version"`
+  Etf string `json:"etf"`
+  Filings []FilingEntry `json:"filings"`
+}
+
+type FilingEntry struct {
+  FilingDate string `json:"filing_date"`
+  // Only present for the filings fetched after its introduction.
+  AccessionNumber string `json:"accession_number,omitempty"`
+  // Name of the file in the ETF's directory.
+  File string `json:"file"`
+}
+
+// filingFileNames returns the file name of each of `indexes`: `<filing_date>.json`, or `<filing_date>.<n>.json`
+// for the n-th filing (from the newest, starting at 2) on an already used date.
+func filingFileNames(indexes []Index) []string {
+  names := []string{}
+  seen := map[string]int{}
+  for _, index := range indexes {
+    seen[index.FilingDate]++
+    if n := seen[index.FilingDate]; n > 1 {
+      names = append(names, fmt.Sprintf("%s.%d.json", index.FilingDate, n))
+    } else {
+      names = append(names, index.FilingDate + ".json")
+    }
+  }
+  return names
+}
+
+func buildFilingsIndex(etf string, indexes []Index) FilingsIndex {
+  res := FilingsIndex{kSchemaVersion, etf, []FilingEntry{}}
+  for i, name := range filingFileNames(indexes) {
+    res.Filings = append(res.Filings, FilingEntry{indexes[i].FilingDate, indexes[i].AccessionNumber, name})
+  }
+  return res
+}
+
+// stageIfChanged stages `v` to `path` unless the file already has this content, which is the case of most
+// past filings.
+func stageIfChanged(batch *fileBatch, path string, schema string, v any) error {
+  encoded, err := marshalDataJson(v)
+  if err != nil {
+    return err
+  }
+  if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, encoded) {
+    return nil
+  }
+  if err := batch.add(path, schema, v); err != nil {
+    return fmt.Errorf("writing to file %s: %w", path, err)
+  }
+  return nil
+}
+
+// byFilingIndex returns `index` as written to its file. The indexes read from older files may predate the schema.
+func byFilingIndex(index Index) Index {
+  index.SchemaVersion = kSchemaVersion
+  return index
+}
+
+// stageByFiling stages the filings of `etf` to `data/by_filing/<ETF>/`, one per file, along with its index file.
+// The files of the filings that aren't in `indexes` anymore are removed.
+func stageByFiling(batch *fileBatch, dataDir, etf string, indexes []Index) error {
+  dir := filepath.Join(dataDir, "by_filing", etf)
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return err
+  }
+  filingsIndex := buildFilingsIndex(etf, indexes)
+  expected := map[string]bool{kFilingsIndexFile: true}
+  for i, entry := range filingsIndex.Filings {
+    expected[entry.File] = true
+    if err := stageIfChanged(batch, filepath.Join(dir, entry.File), kIndexSchema, byFilingIndex(indexes[i])); err != nil {
+      return err
+    }
+  }
+
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return err
+  }
+  for _, entry := range entries {
+    // Skip the temporary files.
+    if !expected[entry.Name()] && strings.HasSuffix(entry.Name(), ".json") && !strings.HasPrefix(entry.Name(), ".") {
+      batch.remove(filepath.Join(dir, entry.Name()))
+    }
+  }
+  // Written after the filings so that it never lists a missing file.
+  return stageIfChanged(batch, filepath.Join(dir, kFilingsIndexFile), kFilingsIndexSchema, filingsIndex)
+}
+
+// readFilingsIndex returns the index file of `etf` in data/by_filing.
+func readFilingsIndex(dataDir, etf string) (FilingsIndex, error) {
+  res := FilingsIndex{}
+  err := readJsonFile(filepath.Join(dataDir, "by_filing", etf, kFilingsIndexFile), &res)
+  return res, err
+}
+
+// validateByFiling checks that data/by_filing/<ETF>/ matches the history of `etf`, if it exists.
+func validateByFiling(res *ValidationResult, dataDir, etf string, indexes []Index) error {
+  filingsIndex, err := readFilingsIndex(dataDir, etf)
+  if errors.Is(err, fs.ErrNotExist) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  if expected := buildFilingsIndex(etf, indexes); !reflect.DeepEqual(filingsIndex, expected) {
+    res.addError(fmt.Sprintf("%s doesn't list the filings of %s", filepath.Join(dataDir, "by_filing", etf, kFilingsIndexFile), etf))
+    return nil
+  }
+  for i, entry := range filingsIndex.Filings {
+    path := filepath.Join(dataDir, "by_filing", etf, entry.File)
+    index := Index{}
+    if err := readJsonFile(path, &index); err != nil {
+      res.addError(fmt.Sprintf("Unreadable filing %s (err=%+v)", path, err))
+      continue
+    }
+    if !reflect.DeepEqual(index, byFilingIndex(indexes[i])) {
+      res.addError(fmt.Sprintf("Filing %s doesn't match the %s filing of %s", path, entry.FilingDate, etf))
+    }
+  }
+  return nil
+}
+
+// runByFiling writes data/by_filing/ from the histories, e.g. for the ETFs without a new filing since the fetch
+// started writing it. Like the fetch, it only rewrites the files that changed.
+func runByFiling(args []string) error {
+  flags := flag.NewFlagSet("by_filing", flag.ExitOnError)
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to write. Defaults to all ETFs")
+  flags.Parse(args)
+
+  lock, err := acquireDataLock(kLockFile)
+  if err != nil {
+    return err
+  }
+  defer lock.release() // ignore error; the lock becomes stale anyway
+  if err := recoverFileBatch(kJournalFile); err != nil {
+    return err
+  }
+
+  batch := newFileBatch(kJournalFile)
+  etfs := 0
+  for _, etf := range selectEtfs(*etfsFlag) {
+    indexes, err := readAllIndexes(etf)
+    if errors.Is(err, fs.ErrNotExist) {
+      continue
+    }
+    if err == nil {
+      err = stageByFiling(batch, "./data", etf, indexes)
+    }
+    if err != nil {
+      batch.discard()
+      return fmt.Errorf("writing the filings of %s: %w", etf, err)
+    }
+    etfs++
+  }
+  staged := len(batch.files)
+  if staged == 0 {
+    batch.discard()
+    fmt.Printf("The filings of the %d ETFs are up to date\n", etfs)
+    return nil
+  }
+  if err := stageManifest(batch, "./data"); err != nil {
+    batch.discard()
+    return err
+  }
+  if err := batch.commit(); err != nil {
+    return err
+  }
+  fmt.Printf("Wrote %d files for %d ETFs\n", staged, etfs)
+  return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+func TestStageByFiling(t *testing.T) {
+  dataDir := t.TempDir()
+  journalPath := filepath.Join(dataDir, ".pending_writes.json")
+  dir := filepath.Join(dataDir, "by_filing", "VXF")
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 2}
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  newest := Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{bond, stock}}
+  // A second filing on the newest date.
+  duplicate := newest
+  duplicate.Components = []IndexComponent{bond}
+  indexes := []Index{newest, duplicate, Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}}}
+
+  tt := []struct {
+    name string
+    indexes []Index
+    // Number of files written or removed by the batch.
+    staged int
+    expectedFiles []string
+  } {
+    {"Initial", indexes, 4, []string{"2025-01-01.json", "2025-02-01.2.json", "2025-02-01.json", "filings.json"}},
+    {"Unchanged", indexes, 0, []string{"2025-01-01.json", "2025-02-01.2.json", "2025-02-01.json", "filings.json"}},
+    // The duplicate is gone, only its file and the index change.
+    {"Removed filing", []Index{indexes[0], indexes[2]}, 2, []string{"2025-01-01.json", "2025-02-01.json", "filings.json"}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      batch := newFileBatch(journalPath)
+      if err := stageByFiling(batch, dataDir, "VXF", tc.indexes); err != nil {
+        t.Errorf("Failed to stage (err=%+v)", err)
+        return
+      }
+      if len(batch.files) != tc.staged {
+        t.Errorf("Expected %d staged files but got %+v", tc.staged, batch.files)
+        return
+      }
+      if err := batch.commit(); err != nil {
+        t.Errorf("Failed to commit (err=%+v)", err)
+        return
+      }
+      if files := listDir(t, dir); !reflect.DeepEqual(files, tc.expectedFiles) {
+        t.Errorf("Expected the files %v but got %v", tc.expectedFiles, files)
+        return
+      }
+
+      filingsIndex, err := readFilingsIndex(dataDir, "VXF")
+      if err != nil || len(filingsIndex.Filings) != len(tc.indexes) || filingsIndex.Filings[0].File != "2025-02-01.json" {
+        t.Errorf("Unexpected index file %+v (err=%+v)", filingsIndex, err)
+        return
+      }
+      res := ValidationResult{}
+      if err := validateByFiling(&res, dataDir, "VXF", tc.indexes); err != nil || len(res.errors) != 0 {
+        t.Errorf("Expected the files to match the history but got %+v (err=%+v)", res.errors, err)
+      }
+    })
+  }
+
+  // A filing that doesn't match the history.
+  if err := writeToJsonFile(filepath.Join(dir, "2025-01-01.json"), indexes[0]); err != nil {
+    t.Errorf("Failed to overwrite the filing (err=%+v)", err)
+    return
+  }
+  res := ValidationResult{}
+  if err := validateByFiling(&res, dataDir, "VXF", []Index{indexes[0], indexes[2]}); err != nil || len(res.errors) != 1 {
+    t.Errorf("Expected a mismatched filing but got %+v (err=%+v)", res.errors, err)
+  }
+}
+
+func TestRunByFiling(t *testing.T) {
+  t.Chdir(t.TempDir())
+  for _, dir := range []string{"all", "latest"} {
+    if err := os.MkdirAll(filepath.Join("data", dir), 0755); err != nil {
+      t.Fatalf("Failed to create the data directory (err=%+v)", err)
+    }
+  }
+  // Written before the schema, without `schema_version`.
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{stock}},
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+  if err := writeToJsonFile(filepath.Join("data", "all", "VXF.json"), indexes); err != nil {
+    t.Fatalf("Failed to write the history (err=%+v)", err)
+  }
+
+  // VTI doesn't have a history.
+  for range 2 {
+    if err := runByFiling([]string{"-etfs", "VTI,VXF"}); err != nil {
+      t.Errorf("Failed to write the filings (err=%+v)", err)
+      return
+    }
+    if files := listDir(t, filepath.Join("data", "by_filing", "VXF")); !reflect.DeepEqual(files, []string{"2025-01-01.json", "2025-02-01.json", "filings.json"}) {
+      t.Errorf("Mismatched files %v", files)
+      return
+    }
+  }
+  res := ValidationResult{}
+  if err := validateByFiling(&res, "data", "VXF", indexes); err != nil || len(res.errors) != 0 {
+    t.Errorf("Expected the filings to match the history but got %v (err=%+v)", res.errors, err)
+    return
+  }
+  manifest, err := readManifest("data")
+  if _, ok := manifest.Files["by_filing/VXF/filings.json"]; err != nil || !ok {
+    t.Errorf("Expected the filings in the manifest but got %+v (err=%+v)", manifest, err)
+  }
+}
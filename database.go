@@ -0,0 +1,357 @@
+package main
+
+import (
+  "crypto/sha256"
+  "database/sql"
+  "encoding/hex"
+  "errors"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+  "flag"
+  "fmt"
+  "io/fs"
+  "maps"
+  "os"
+  "slices"
+  _ "modernc.org/sqlite"
+)
+
+// SQLite database with the whole dataset, see runDatabase.
+const kDatabaseFile = "./data/etfs.db"
+// Stored in `PRAGMA user_version`. Bump it when changing kDatabaseSchema: the database is then rebuilt from scratch.
+const kDatabaseVersion = 1
+
+var kDatabaseSchema = []string{
+  `CREATE TABLE ciks (
+    cik INTEGER PRIMARY KEY,
+    -- Span of the fetched filing dates (fetched_map.json), NULL if nothing was fetched.
+    fetched_start TEXT,
+    fetched_end TEXT
+  )`,
+  `CREATE TABLE etfs (
+    etf TEXT PRIMARY KEY,
+    cik INTEGER NOT NULL REFERENCES ciks(cik),
+    series_id TEXT NOT NULL
+  )`,
+  `CREATE TABLE filings (
+    filing_id INTEGER PRIMARY KEY,
+    etf TEXT NOT NULL REFERENCES etfs(etf),
+    -- As filed, which may differ from etfs.series_id.
+    series_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    filing_date TEXT NOT NULL,
+    -- NULL for the filings fetched before they were stored.
+    report_date TEXT,
+    accession_number TEXT
+  )`,
+  `CREATE INDEX filings_by_etf ON filings(etf, filing_date)`,
+  // Securities are deduplicated by identifier across ETFs and filings.
+  `CREATE TABLE securities (
+    security_id INTEGER PRIMARY KEY,
+    id_type TEXT NOT NULL,
+    id TEXT NOT NULL,
+    -- Name in the last imported filing holding the security.
+    name TEXT NOT NULL,
+    UNIQUE (id_type, id)
+  )`,
+  `CREATE TABLE holdings (
+    filing_id INTEGER NOT NULL REFERENCES filings(filing_id),
+    -- Position of the component in the filing, starting at 0.
+    position INTEGER NOT NULL,
+    security_id INTEGER NOT NULL REFERENCES securities(security_id),
+    -- As filed.
+    name TEXT NOT NULL,
+    weight REAL NOT NULL,
+    PRIMARY KEY (filing_id, position)
+  ) WITHOUT ROWID`,
+  // "Which ETFs hold X" and, with filings_by_etf, "history of X in ETF Y".
+  `CREATE INDEX holdings_by_security ON holdings(security_id, filing_id)`,
+  // Checksums of the imported data/all files (in either format), to only reimport the ones that changed.
+  `CREATE TABLE sources (
+    etf TEXT PRIMARY KEY,
+    sha256 TEXT NOT NULL
+  )`,
+  `CREATE VIEW holding_history AS
+    SELECT f.etf, f.filing_date, s.id, s.id_type, h.name, h.weight
+    FROM holdings h JOIN filings f USING (filing_id) JOIN securities s USING (security_id)`,
+}
+
+// openDatabase opens the database at `path`, creating it if needed.
+// A database with another version is deleted and recreated.
+func openDatabase(path string) (*sql.DB, error) {
+  for {
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+      return nil, err
+    }
+    // A single connection so that the transactions don't compete for the file.
+    db.SetMaxOpenConns(1)
+
+    version := 0
+    if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+      db.Close() // ignore error; QueryRow error takes precedence
+      return nil, err
+    }
+    if version == kDatabaseVersion {
+      return db, nil
+    }
+    if version == 0 {
+      if err := createDatabaseSchema(db); err != nil {
+        db.Close() // ignore error; the creation error takes precedence
+        return nil, err
+      }
+      return db, nil
+    }
+
+    fmt.Printf("Rebuilding %s from scratch as it has version %d instead of %d\n", path, version, kDatabaseVersion)
+    if err := db.Close(); err != nil {
+      return nil, err
+    }
+    if err := os.Remove(path); err != nil {
+      return nil, err
+    }
+  }
+}
+
+func createDatabaseSchema(db *sql.DB) error {
+  tx, err := db.Begin()
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback() // ignore error; no-op after Commit
+  for _, statement := range kDatabaseSchema {
+    if _, err := tx.Exec(statement); err != nil {
+      return fmt.Errorf("creating the schema: %w", err)
+    }
+  }
+  if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", kDatabaseVersion)); err != nil {
+    return err
+  }
+  return tx.Commit()
+}
+
+// nullString maps "" to NULL.
+func nullString(s string) sql.NullString {
+  return sql.NullString{String: s, Valid: s != ""}
+}
+
+type databaseUpdate struct {
+  imported []string
+  unchanged []string
+  // ETFs without data/all file.
+  missing []string
+  // ETFs not in our map anymore.
+  removed []string
+}
+
+// deleteEtfFilings removes the filings of `etf` and their holdings.
+func deleteEtfFilings(tx *sql.Tx, etf string) error {
+  if _, err := tx.Exec("DELETE FROM holdings WHERE filing_id IN (SELECT filing_id FROM filings WHERE etf = ?)", etf); err != nil {
+    return err
+  }
+  if _, err := tx.Exec("DELETE FROM filings WHERE etf = ?", etf); err != nil {
+    return err
+  }
+  _, err := tx.Exec("DELETE FROM sources WHERE etf = ?", etf)
+  return err
+}
+
+// updateReferenceTables replaces the CIKs and ETFs with our map, removing the filings of the ETFs not in it anymore.
+func updateReferenceTables(tx *sql.Tx, fetched FetchedDatesMap, res *databaseUpdate) error {
+  rows, err := tx.Query("SELECT etf FROM etfs")
+  if err != nil {
+    return err
+  }
+  stored := []string{}
+  for rows.Next() {
+    etf := ""
+    if err := rows.Scan(&etf); err != nil {
+      rows.Close() // ignore error; Scan error takes precedence
+      return err
+    }
+    stored = append(stored, etf)
+  }
+  if err := rows.Close(); err != nil {
+    return err
+  }
+
+  known := map[string]bool{}
+  for _, etf := range seriesToEtfs {
+    known[etf] = true
+  }
+  for _, etf := range stored {
+    if known[etf] {
+      continue
+    }
+    if err := deleteEtfFilings(tx, etf); err != nil {
+      return err
+    }
+    res.removed = append(res.removed, etf)
+  }
+  if _, err := tx.Exec("DELETE FROM etfs"); err != nil {
+    return err
+  }
+  if _, err := tx.Exec("DELETE FROM ciks"); err != nil {
+    return err
+  }
+
+  for _, cik := range slices.Sorted(maps.Keys(cikToEtfs)) {
+    span := fetched[cik]
+    if _, err := tx.Exec("INSERT INTO ciks (cik, fetched_start, fetched_end) VALUES (?, ?, ?)", cik, nullString(span.Start), nullString(span.End)); err != nil {
+      return err
+    }
+  }
+  for id, etf := range seriesToEtfs {
+    if _, err := tx.Exec("INSERT INTO etfs (etf, cik, series_id) VALUES (?, ?, ?)", etf, id.Cik, id.SeriesId); err != nil {
+      return fmt.Errorf("inserting %s: %w", etf, err)
+    }
+  }
+  return nil
+}
+
+// importEtf replaces the filings of `etf` with `indexes`.
+func importEtf(tx *sql.Tx, etf string, indexes []Index, checksum string) error {
+  if err := deleteEtfFilings(tx, etf); err != nil {
+    return err
+  }
+  insertFiling, err := tx.Prepare("INSERT INTO filings (etf, series_id, name, filing_date, report_date, accession_number) VALUES (?, ?, ?, ?, ?, ?)")
+  if err != nil {
+    return err
+  }
+  defer insertFiling.Close()
+  upsertSecurity, err := tx.Prepare("INSERT INTO securities (id_type, id, name) VALUES (?, ?, ?) ON CONFLICT (id_type, id) DO UPDATE SET name = excluded.name RETURNING security_id")
+  if err != nil {
+    return err
+  }
+  defer upsertSecurity.Close()
+  insertHolding, err := tx.Prepare("INSERT INTO holdings (filing_id, position, security_id, name, weight) VALUES (?, ?, ?, ?, ?)")
+  if err != nil {
+    return err
+  }
+  defer insertHolding.Close()
+
+  // The indexes are ordered from the newest so we only update the security's name on its first occurrence.
+  securityIds := map[[2]string]int64{}
+  for _, index := range indexes {
+    res, err := insertFiling.Exec(etf, index.SeriesId, index.Name, index.FilingDate, nullString(index.ReportDate), nullString(index.AccessionNumber))
+    if err != nil {
+      return err
+    }
+    filingId, err := res.LastInsertId()
+    if err != nil {
+      return err
+    }
+    for position, component := range index.Components {
+      key := [2]string{component.IdType, component.Id}
+      securityId, ok := securityIds[key]
+      if !ok {
+        if err := upsertSecurity.QueryRow(component.IdType, component.Id, component.Name).Scan(&securityId); err != nil {
+          return err
+        }
+        securityIds[key] = securityId
+      }
+      if _, err := insertHolding.Exec(filingId, position, securityId, component.Name, component.Weight); err != nil {
+        return err
+      }
+    }
+  }
+  _, err = tx.Exec("INSERT INTO sources (etf, sha256) VALUES (?, ?)", etf, checksum)
+  return err
+}
+
+// updateDatabase imports the data/all files under `dataDir` that changed since the last update.
+func updateDatabase(db *sql.DB, dataDir string, fetched FetchedDatesMap) (databaseUpdate, error) {
+  res := databaseUpdate{}
+  tx, err := db.Begin()
+  if err != nil {
+    return res, err
+  }
+  defer tx.Rollback() // ignore error; no-op after Commit
+  if err := updateReferenceTables(tx, fetched, &res); err != nil {
+    return res, err
+  }
+
+  etfs := []string{}
+  for _, etf := range seriesToEtfs {
+    etfs = append(etfs, etf)
+  }
+  slices.Sort(etfs)
+  for _, etf := range etfs {
+    path, delta := historyFile(dataDir, etf)
+    bytes, err := os.ReadFile(path)
+    if errors.Is(err, fs.ErrNotExist) {
+      if err := deleteEtfFilings(tx, etf); err != nil {
+        return res, err
+      }
+      res.missing = append(res.missing, etf)
+      continue
+    }
+    if err != nil {
+      return res, err
+    }
+
+    sum := sha256.Sum256(bytes)
+    checksum := hex.EncodeToString(sum[:])
+    stored := ""
+    if err := tx.QueryRow("SELECT sha256 FROM sources WHERE etf = ?", etf).Scan(&stored); err != nil && !errors.Is(err, sql.ErrNoRows) {
+      return res, err
+    }
+    if stored == checksum {
+      res.unchanged = append(res.unchanged, etf)
+      continue
+    }
+
+    indexes, err := etf_data.DecodeHistory(bytes, delta)
+    if err != nil {
+      return res, fmt.Errorf("parsing %s: %w", path, err)
+    }
+    if err := importEtf(tx, etf, indexes, checksum); err != nil {
+      return res, fmt.Errorf("importing %s: %w", path, err)
+    }
+    res.imported = append(res.imported, etf)
+  }
+
+  if _, err := tx.Exec("DELETE FROM securities WHERE security_id NOT IN (SELECT security_id FROM holdings)"); err != nil {
+    return res, err
+  }
+  return res, tx.Commit()
+}
+
+// runDatabase builds or updates the SQLite database from all_etfs.json and the data directory.
+// Only the ETFs whose file changed are reimported so it can be run after each fetch.
+func runDatabase(args []string) error {
+  flags := flag.NewFlagSet("database", flag.ExitOnError)
+  dbFlag := flags.String("db", kDatabaseFile, "Path of the SQLite database")
+  rebuildFlag := flags.Bool("rebuild", false, "Rebuild the database from scratch instead of only importing the changed ETFs")
+  flags.Parse(args)
+
+  // The fetch may be writing the data directory, and the database is in it.
+  lock, err := acquireDataLock(kLockFile)
+  if err != nil {
+    return err
+  }
+  defer lock.release() // ignore error; the lock becomes stale anyway
+  if err := recoverFileBatch(kJournalFile); err != nil {
+    return err
+  }
+
+  fetched := FetchedDatesMap{}
+  if err := readJsonFile(kFetchedMapFile, &fetched); err != nil {
+    return fmt.Errorf("reading %s: %w", kFetchedMapFile, err)
+  }
+  if *rebuildFlag {
+    if err := os.Remove(*dbFlag); err != nil && !errors.Is(err, fs.ErrNotExist) {
+      return err
+    }
+  }
+  db, err := openDatabase(*dbFlag)
+  if err != nil {
+    return err
+  }
+  res, err := updateDatabase(db, "./data", fetched)
+  if err != nil {
+    db.Close() // ignore error; the update error takes precedence
+    return err
+  }
+  fmt.Printf("Updated %s: imported=%v, unchanged=%d ETFs, missing=%v, removed=%v\n", *dbFlag, res.imported, len(res.unchanged), res.missing, res.removed)
+  return db.Close()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+func TestUpdateDatabase(t *testing.T) {
+  dataDir := t.TempDir()
+  if err := os.MkdirAll(filepath.Join(dataDir, "all"), 0755); err != nil {
+    t.Fatalf("Failed to create the data directory (err=%+v)", err)
+  }
+  allPath := filepath.Join(dataDir, "all", "VXF.json")
+  db, err := openDatabase(filepath.Join(t.TempDir(), "etfs.db"))
+  if err != nil {
+    t.Fatalf("Failed to open the database (err=%+v)", err)
+  }
+  defer db.Close()
+
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 2}
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", AccessionNumber: "000110465925103792", Components: []IndexComponent{bond, stock}},
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+  tt := []struct {
+    name string
+    // nil to remove the file.
+    indexes []Index
+    imported bool
+    filings int
+    holdings int
+    securities int
+  } {
+    {"Initial import", indexes, true, 2, 3, 2},
+    {"Unchanged", indexes, false, 2, 3, 2},
+    // The bond isn't held anymore.
+    {"Updated", indexes[1:], true, 1, 1, 1},
+    {"Removed", nil, false, 0, 0, 0},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      if tc.indexes != nil {
+        if err := writeToJsonFile(allPath, tc.indexes); err != nil {
+          t.Errorf("Failed to write %s (err=%+v)", allPath, err)
+          return
+        }
+      } else if err := os.Remove(allPath); err != nil {
+        t.Errorf("Failed to remove %s (err=%+v)", allPath, err)
+        return
+      }
+
+      res, err := updateDatabase(db, dataDir, FetchedDatesMap{kCompanyId: FilingDateSpan{Start: "2024-01-01", End: kDate}})
+      if err != nil {
+        t.Errorf("Failed to update the database (err=%+v)", err)
+        return
+      }
+      if imported := reflect.DeepEqual(res.imported, []string{"VXF"}); imported != tc.imported {
+        t.Errorf("Expected imported=%t but got %+v", tc.imported, res)
+        return
+      }
+
+      counts := []int{0, 0, 0}
+      for i, table := range []string{"filings", "holdings", "securities"} {
+        if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&counts[i]); err != nil {
+          t.Errorf("Failed to count the %s (err=%+v)", table, err)
+          return
+        }
+      }
+      if expected := []int{tc.filings, tc.holdings, tc.securities}; !reflect.DeepEqual(counts, expected) {
+        t.Errorf("Expected filings, holdings and securities=%v but got %v", expected, counts)
+        return
+      }
+      if tc.holdings == 0 {
+        return
+      }
+
+      // History of a security in an ETF.
+      rows, err := db.Query("SELECT filing_date, weight FROM holding_history WHERE etf = ? AND id_type = ? AND id = ? ORDER BY filing_date", "VXF", "isin", "US5324571083")
+      if err != nil {
+        t.Errorf("Failed to query the history (err=%+v)", err)
+        return
+      }
+      defer rows.Close()
+      dates := []string{}
+      for rows.Next() {
+        date := ""
+        weight := float32(0)
+        if err := rows.Scan(&date, &weight); err != nil || weight != 1.5 {
+          t.Errorf("Unexpected weight %f (err=%+v)", weight, err)
+          return
+        }
+        dates = append(dates, date)
+      }
+      expectedDates := []string{kDate, "2025-02-01"}
+      if tc.filings == 1 {
+        expectedDates = expectedDates[:1]
+      }
+      if !reflect.DeepEqual(dates, expectedDates) {
+        t.Errorf("Expected the history %v but got %v", expectedDates, dates)
+      }
+    })
+  }
+
+  span := []string{"", ""}
+  if err := db.QueryRow("SELECT fetched_start, fetched_end FROM ciks WHERE cik = ?", kCompanyId).Scan(&span[0], &span[1]); err != nil || span[1] != kDate {
+    t.Errorf("Unexpected fetched span %v for %d (err=%+v)", span, kCompanyId, err)
+  }
+}
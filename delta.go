@@ -0,0 +1,105 @@
+package main
+
+import (
+  "errors"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+  "fmt"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "reflect"
+  "slices"
+)
+
+// Formats of the history files in data/all, see `-history_format`.
+const kFullHistory = "full"
+const kDeltaHistory = "delta"
+
+// Format of the history files written by the fetch. The files are read in either format.
+var historyFormat = kFullHistory
+
+func sameComponentButWeight(a, b IndexComponent) bool {
+  a.Weight = b.Weight
+  return reflect.DeepEqual(a, b)
+}
+
+// diffIndex returns `index` encoded against `previous`.
+func diffIndex(previous, index Index) IndexDelta {
+  // Unused positions of the previous components by identifier, so that duplicated identifiers are matched in order.
+  positions := map[[2]string][]int{}
+  for i, component := range previous.Components {
+    key := [2]string{component.IdType, component.Id}
+    positions[key] = append(positions[key], i)
+  }
+
+  delta := IndexDelta{Index: index, Components: make([]ComponentRef, 0, len(index.Components))}
+  delta.Index.Components = nil
+  for _, component := range index.Components {
+    key := [2]string{component.IdType, component.Id}
+    ref := ComponentRef{Added: &component}
+    for i, position := range positions[key] {
+      if !sameComponentButWeight(previous.Components[position], component) {
+        continue
+      }
+      positions[key] = slices.Delete(positions[key], i, i + 1)
+      ref = ComponentRef{Previous: &position}
+      if previous.Components[position].Weight != component.Weight {
+        ref.Weight = &component.Weight
+      }
+      break
+    }
+    delta.Components = append(delta.Components, ref)
+  }
+  return delta
+}
+
+// encodeDeltaHistory encodes `indexes`, ordered from the newest to the oldest like in data/all.
+func encodeDeltaHistory(indexes []Index) (DeltaHistory, error) {
+  if len(indexes) == 0 {
+    return DeltaHistory{}, errors.New("no filing to encode")
+  }
+  res := DeltaHistory{SchemaVersion: kSchemaVersion, Base: indexes[len(indexes) - 1], Deltas: []IndexDelta{}}
+  for i := len(indexes) - 2; i >= 0; i-- {
+    res.Deltas = append(res.Deltas, diffIndex(indexes[i + 1], indexes[i]))
+  }
+  return res, nil
+}
+
+func historyPaths(dataDir, etf string) (full, delta string) {
+  full, delta = etf_data.HistoryPaths(etf)
+  return filepath.Join(dataDir, full), filepath.Join(dataDir, delta)
+}
+
+// historyFile returns the path of the history file of `etf` and whether it's delta-encoded.
+func historyFile(dataDir, etf string) (string, bool) {
+  name, delta := etf_data.HistoryFile(os.DirFS(dataDir), etf)
+  return filepath.Join(dataDir, name), delta
+}
+
+// readHistory returns the stored indexes of `etf` in either format, ordered from the newest to the oldest.
+func readHistory(dataDir, etf string) ([]Index, error) {
+  return etf_data.ReadHistory(os.DirFS(dataDir), etf)
+}
+
+// stageHistory stages the history of `etf` in `historyFormat`, removing its file in the other format.
+func stageHistory(batch *fileBatch, dataDir, etf string, indexes []Index) error {
+  full, delta := historyPaths(dataDir, etf)
+  path, schema, stale := full, kAllSchema, delta
+  var v any = indexes
+  if historyFormat == kDeltaHistory {
+    h, err := encodeDeltaHistory(indexes)
+    if err != nil {
+      return err
+    }
+    path, schema, stale, v = delta, kDeltaHistorySchema, full, h
+  }
+  if err := batch.add(path, schema, v); err != nil {
+    return fmt.Errorf("writing to file %s: %w", path, err)
+  }
+  if _, err := os.Stat(stale); err == nil {
+    batch.remove(stale)
+  } else if !errors.Is(err, fs.ErrNotExist) {
+    return err
+  }
+  return nil
+}
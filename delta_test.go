@@ -0,0 +1,203 @@
+package main
+
+import (
+  "encoding/json"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+  "os"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+func TestDeltaHistoryRoundTrip(t *testing.T) {
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 2.0219882, Debt: &DebtInfo{MaturityDate: "2050-02-15", CouponKind: "none", Convertible: &ConvertibleInfo{IsMandatory: true, References: []ConvertibleReference{ConvertibleReference{Name: "Company", Title: "Common Stock", ConversionRatio: 1.5, Currency: "USD"}}}}}
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5, Lending: &LendingInfo{OnLoan: true, LoanValue: 1000.5}, Lots: []ComponentLot{ComponentLot{Line: 1, Name: "Eli Lilly & Co", Weight: 1}, ComponentLot{Line: 3, Name: "Eli Lilly & Co", Weight: 0.5}}}
+  newStock := IndexComponent{Name: "Apple Inc", Id: "US0378331005", IdType: "isin", Weight: 3}
+  reweightedStock := stock
+  reweightedStock.Weight = 2
+  renamedStock := stock
+  renamedStock.Name = "Eli Lilly and Co"
+  filing := func (date string, components ...IndexComponent) Index {
+    return Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: date, Components: append([]IndexComponent{}, components...)}
+  }
+
+  tt := []struct {
+    name string
+    // From the newest to the oldest.
+    indexes []Index
+  } {
+    {"Single filing", []Index{filing(kDate, stock)}},
+    {"Nested objects", []Index{filing("2025-02-01", bond, stock), filing(kDate, stock)}},
+    {"Added and removed", []Index{filing("2025-03-01", newStock), filing("2025-02-01", bond, newStock), filing(kDate, bond, stock)}},
+    {"Reweighted and reordered", []Index{filing("2025-02-01", reweightedStock, bond), filing(kDate, bond, stock)}},
+    {"Changed beyond the weight", []Index{filing("2025-02-01", renamedStock), filing(kDate, stock)}},
+    {"Duplicated identifier", []Index{filing("2025-02-01", reweightedStock, stock, stock), filing(kDate, stock, reweightedStock)}},
+    {"Empty filing", []Index{filing("2025-02-01"), filing(kDate, stock)}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      dataDir := t.TempDir()
+      if err := os.MkdirAll(filepath.Join(dataDir, "all"), 0755); err != nil {
+        t.Fatalf("Failed to create the data directory (err=%+v)", err)
+      }
+      // The current format, read back like the stored files.
+      full, delta := historyPaths(dataDir, "VXF")
+      if err := writeToJsonFile(full, tc.indexes); err != nil {
+        t.Errorf("Failed to write %s (err=%+v)", full, err)
+        return
+      }
+      expected, err := readHistory(dataDir, "VXF")
+      if err != nil {
+        t.Errorf("Failed to read %s (err=%+v)", full, err)
+        return
+      }
+
+      h, err := encodeDeltaHistory(expected)
+      if err != nil {
+        t.Errorf("Failed to encode (err=%+v)", err)
+        return
+      }
+      if err := validateSchema(kDeltaHistorySchema, h); err != nil {
+        t.Errorf("Invalid delta-encoded history (err=%+v)", err)
+        return
+      }
+      if err := writeToJsonFile(delta, h); err != nil {
+        t.Errorf("Failed to write %s (err=%+v)", delta, err)
+        return
+      }
+      // The delta-encoded file takes precedence.
+      indexes, err := readHistory(dataDir, "VXF")
+      if err != nil {
+        t.Errorf("Failed to read %s (err=%+v)", delta, err)
+        return
+      }
+      if !reflect.DeepEqual(indexes, expected) {
+        t.Errorf("Mismatched indexes, expected=%+v but got=%+v", expected, indexes)
+      }
+    })
+  }
+}
+
+func TestDeltaHistoryEncoding(t *testing.T) {
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 2}
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{bond, stock}},
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+  h, err := encodeDeltaHistory(indexes)
+  if err != nil {
+    t.Errorf("Failed to encode (err=%+v)", err)
+    return
+  }
+  // The oldest filing only holds the stock, which is kept at the same weight, and the bond is added.
+  bytes, err := json.Marshal(h.Deltas)
+  if err != nil {
+    t.Errorf("Failed to marshal (err=%+v)", err)
+    return
+  }
+  if !reflect.DeepEqual(h.Base, indexes[1]) || len(h.Deltas) != 1 || h.Deltas[0].Components[0].Added == nil || *h.Deltas[0].Components[1].Previous != 0 || h.Deltas[0].Components[1].Weight != nil {
+    t.Errorf("Unexpected encoding %s", bytes)
+  }
+}
+
+func TestDeltaHistoryInvalid(t *testing.T) {
+  tt := []struct {
+    name string
+    json string
+  } {
+    {"Out of range reference", `{"schema_version":1,"base":{"components":[]},"deltas":[{"components":[{"previous":0}]}]}`},
+    {"Empty reference", `{"schema_version":1,"base":{"components":[]},"deltas":[{"components":[{}]}]}`},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      if indexes, err := etf_data.DecodeHistory([]byte(tc.json), true); err == nil {
+        t.Errorf("Expected an error but got %+v", indexes)
+      }
+    })
+  }
+}
+
+func TestStageHistory(t *testing.T) {
+  dataDir := t.TempDir()
+  if err := os.MkdirAll(filepath.Join(dataDir, "all"), 0755); err != nil {
+    t.Fatalf("Failed to create the data directory (err=%+v)", err)
+  }
+  defer func() { historyFormat = kFullHistory }()
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{stock}},
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+
+  // Switching the format replaces the file in the other format.
+  for _, tc := range []struct {
+    format string
+    expected string
+  } {
+    {kFullHistory, "VXF.json"},
+    {kDeltaHistory, "VXF.delta.json"},
+    {kFullHistory, "VXF.json"},
+  } {
+    historyFormat = tc.format
+    batch := newFileBatch(filepath.Join(dataDir, ".pending_writes.json"))
+    if err := stageHistory(batch, dataDir, "VXF", indexes); err != nil {
+      t.Errorf("Failed to stage the %s history (err=%+v)", tc.format, err)
+      return
+    }
+    if err := batch.commit(); err != nil {
+      t.Errorf("Failed to commit the %s history (err=%+v)", tc.format, err)
+      return
+    }
+    if files := listDir(t, filepath.Join(dataDir, "all")); len(files) != 1 || files[0] != tc.expected {
+      t.Errorf("Expected only %s but got %v", tc.expected, files)
+      return
+    }
+    if actual, err := readHistory(dataDir, "VXF"); err != nil || !reflect.DeepEqual(actual, indexes) {
+      t.Errorf("Mismatched %s history %+v (err=%+v)", tc.format, actual, err)
+      return
+    }
+  }
+}
+
+// Round trip of the stored histories, which cover years of real reweights, additions and removals.
+func TestDeltaHistoryStoredData(t *testing.T) {
+  if testing.Short() {
+    t.Skip("Skipping the stored data in short mode")
+  }
+  paths, err := filepath.Glob("./data/all/*.json")
+  if err != nil || len(paths) == 0 {
+    t.Skipf("No stored data (err=%+v)", err)
+  }
+  for _, path := range paths {
+    t.Run(filepath.Base(path), func (t *testing.T) {
+      bytes, err := os.ReadFile(path)
+      if err != nil {
+        t.Errorf("Failed to read %s (err=%+v)", path, err)
+        return
+      }
+      expected, err := etf_data.DecodeHistory(bytes, false)
+      if err != nil || len(expected) == 0 {
+        t.Skipf("Not a full history (err=%+v)", err)
+      }
+      h, err := encodeDeltaHistory(expected)
+      if err != nil {
+        t.Errorf("Failed to encode (err=%+v)", err)
+        return
+      }
+      deltaBytes, err := json.Marshal(h)
+      if err != nil {
+        t.Errorf("Failed to marshal (err=%+v)", err)
+        return
+      }
+      indexes, err := etf_data.DecodeHistory(deltaBytes, true)
+      if err != nil || !reflect.DeepEqual(indexes, expected) {
+        t.Errorf("Mismatched round trip of %s (err=%+v)", path, err)
+        return
+      }
+      t.Logf("%s: %d bytes, %d delta-encoded", path, len(bytes), len(deltaBytes))
+    })
+  }
+}
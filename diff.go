@@ -0,0 +1,297 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "slices"
+  "strings"
+)
+
+// Kinds of HoldingChange.
+const kHoldingAdded = "added"
+const kHoldingRemoved = "removed"
+const kHoldingReweighted = "reweighted"
+
+// HoldingChange is a component that differs between two filings.
+type HoldingChange struct {
+  Kind string `json:"kind"`
+  // From the newer filing, except for the removed components.
+  Name string `json:"name"`
+  Id string `json:"id"`
+  IdType string `json:"id_type"`
+  // Set if the components were matched by name, their identifier having changed (e.g. from a synthetic one).
+  PreviousId string `json:"previous_id,omitempty"`
+  // 0 for the added components.
+  OldWeight float32 `json:"old_weight"`
+  // 0 for the removed components.
+  NewWeight float32 `json:"new_weight"`
+}
+
+func (c HoldingChange) weightChange() float64 {
+  return float64(c.NewWeight) - float64(c.OldWeight)
+}
+
+// FilingDiff is the difference between two filings of an ETF.
+type FilingDiff struct {
+  Etf string `json:"etf"`
+  FromDate string `json:"from_date"`
+  ToDate string `json:"to_date"`
+  // Minimum change of weight (in points) of the reweighted components.
+  Threshold float64 `json:"threshold"`
+  // Sorted by decreasing new weight.
+  Added []HoldingChange `json:"added"`
+  // Sorted by decreasing old weight.
+  Removed []HoldingChange `json:"removed"`
+  // Sorted by decreasing absolute change.
+  Reweighted []HoldingChange `json:"reweighted"`
+  // Components in both filings whose weight changed by less than the threshold.
+  Unchanged int `json:"unchanged"`
+}
+
+// normalizedName is the key of the match by name.
+func normalizedName(name string) string {
+  return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// matchComponents returns the position in `from` of the component matching each component of `to` (-1 if
+// none). The components are matched by identifier, in order if it's duplicated. The remaining ones are then
+// matched by name when their identifiers aren't comparable (different types, or synthetic), if the name is
+// unique among them on both sides as several securities may share a name (e.g. "United States Treasury
+// Strip Coupon"), and for bonds if they have the same maturity.
+func matchComponents(from, to []IndexComponent) []int {
+  matches := slices.Repeat([]int{-1}, len(to))
+  matched := make([]bool, len(from))
+  idKey := func (c IndexComponent) string { return c.IdType + "/" + c.Id }
+  positions := map[string][]int{}
+  for i, component := range from {
+    positions[idKey(component)] = append(positions[idKey(component)], i)
+  }
+  for i, component := range to {
+    if candidates := positions[idKey(component)]; len(candidates) > 0 {
+      matches[i] = candidates[0]
+      matched[candidates[0]] = true
+      positions[idKey(component)] = candidates[1:]
+    }
+  }
+
+  // Unmatched positions by name, on each side.
+  fromNames := map[string][]int{}
+  for i, component := range from {
+    if !matched[i] {
+      fromNames[normalizedName(component.Name)] = append(fromNames[normalizedName(component.Name)], i)
+    }
+  }
+  toNames := map[string][]int{}
+  for i, component := range to {
+    if matches[i] == -1 {
+      toNames[normalizedName(component.Name)] = append(toNames[normalizedName(component.Name)], i)
+    }
+  }
+  for name, toPositions := range toNames {
+    fromPositions := fromNames[name]
+    if len(fromPositions) != 1 || len(toPositions) != 1 {
+      continue
+    }
+    a, b := from[fromPositions[0]], to[toPositions[0]]
+    // Different identifiers of the same type are different securities, unless they're derived from the
+    // issuer's fields.
+    if a.IdType == b.IdType && a.IdType != kSyntheticIdType {
+      continue
+    }
+    // Bonds of the same issuer differ by their maturity.
+    if a.Debt != nil && b.Debt != nil && a.Debt.MaturityDate != b.Debt.MaturityDate {
+      continue
+    }
+    matches[toPositions[0]] = fromPositions[0]
+  }
+  return matches
+}
+
+// diffFilings compares the filing `to` of `etf` with the older filing `from`.
+func diffFilings(etf string, from, to Index, threshold float64) FilingDiff {
+  res := FilingDiff{etf, from.FilingDate, to.FilingDate, threshold, []HoldingChange{}, []HoldingChange{}, []HoldingChange{}, 0}
+  matches := matchComponents(from.Components, to.Components)
+  matched := make([]bool, len(from.Components))
+  for i, component := range to.Components {
+    change := HoldingChange{Kind: kHoldingAdded, Name: component.Name, Id: component.Id, IdType: component.IdType, NewWeight: component.Weight}
+    if matches[i] == -1 {
+      res.Added = append(res.Added, change)
+      continue
+    }
+    previous := from.Components[matches[i]]
+    matched[matches[i]] = true
+    if previous.Id != component.Id || previous.IdType != component.IdType {
+      change.PreviousId = previous.Id
+    }
+    change.OldWeight = previous.Weight
+    // Compared with the precision of the weights, e.g. so that 1.5 -> 1.6 is a change of 0.1.
+    if float32(math.Abs(change.weightChange())) <= float32(threshold) {
+      res.Unchanged++
+      continue
+    }
+    change.Kind = kHoldingReweighted
+    res.Reweighted = append(res.Reweighted, change)
+  }
+  for i, component := range from.Components {
+    if !matched[i] {
+      res.Removed = append(res.Removed, HoldingChange{Kind: kHoldingRemoved, Name: component.Name, Id: component.Id, IdType: component.IdType, OldWeight: component.Weight})
+    }
+  }
+
+  slices.SortStableFunc(res.Added, func (a, b HoldingChange) int { return compareFloat(b.NewWeight, a.NewWeight) })
+  slices.SortStableFunc(res.Removed, func (a, b HoldingChange) int { return compareFloat(b.OldWeight, a.OldWeight) })
+  slices.SortStableFunc(res.Reweighted, func (a, b HoldingChange) int {
+    return compareFloat(math.Abs(b.weightChange()), math.Abs(a.weightChange()))
+  })
+  return res
+}
+
+func compareFloat[T float32 | float64](a, b T) int {
+  switch {
+    case a < b:
+      return -1
+    case a > b:
+      return 1
+  }
+  return 0
+}
+
+// findFiling returns the filing of `date`, the newest if there are several on the same date.
+func findFiling(indexes []Index, date string) (Index, bool) {
+  for _, index := range indexes {
+    if index.FilingDate == date {
+      return index, true
+    }
+  }
+  return Index{}, false
+}
+
+// selectDiffFilings returns the filings to compare in `indexes` (from the newest to the oldest): the ones
+// of `fromDate` and `toDate`, by default the two newest.
+func selectDiffFilings(etf string, indexes []Index, fromDate, toDate string) (Index, Index, error) {
+  if fromDate == "" && toDate == "" {
+    if len(indexes) < 2 {
+      return Index{}, Index{}, fmt.Errorf("%s has %d filing(s), at least 2 are needed", etf, len(indexes))
+    }
+    return indexes[1], indexes[0], nil
+  }
+  if fromDate == "" || toDate == "" {
+    return Index{}, Index{}, fmt.Errorf("both -from and -to are needed")
+  }
+  from, ok := findFiling(indexes, fromDate)
+  if !ok {
+    return Index{}, Index{}, fmt.Errorf("%s has no filing on %s", etf, fromDate)
+  }
+  to, ok := findFiling(indexes, toDate)
+  if !ok {
+    return Index{}, Index{}, fmt.Errorf("%s has no filing on %s", etf, toDate)
+  }
+  return from, to, nil
+}
+
+func (c HoldingChange) describe() string {
+  res := fmt.Sprintf("%s (%s %s)", c.Name, c.IdType, c.Id)
+  if c.PreviousId != "" {
+    res += fmt.Sprintf(", previously %s", c.PreviousId)
+  }
+  return res
+}
+
+func writeDiffText(w io.Writer, d FilingDiff) {
+  fmt.Fprintf(w, "%s: %s -> %s (weight changes above %g points)\n", d.Etf, d.FromDate, d.ToDate, d.Threshold)
+  fmt.Fprintf(w, "Added (%d):\n", len(d.Added))
+  for _, c := range d.Added {
+    fmt.Fprintf(w, "  + %9.4f%%  %s\n", c.NewWeight, c.describe())
+  }
+  fmt.Fprintf(w, "Removed (%d):\n", len(d.Removed))
+  for _, c := range d.Removed {
+    fmt.Fprintf(w, "  - %9.4f%%  %s\n", c.OldWeight, c.describe())
+  }
+  fmt.Fprintf(w, "Reweighted (%d):\n", len(d.Reweighted))
+  for _, c := range d.Reweighted {
+    fmt.Fprintf(w, "    %9.4f%% -> %9.4f%% (%+.4f)  %s\n", c.OldWeight, c.NewWeight, c.weightChange(), c.describe())
+  }
+  fmt.Fprintf(w, "Unchanged: %d\n", d.Unchanged)
+}
+
+// markdownCell escapes the characters of `s` that would break a table cell.
+func markdownCell(s string) string {
+  return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func writeDiffMarkdown(w io.Writer, d FilingDiff) {
+  fmt.Fprintf(w, "## %s: %s → %s\n\n", d.Etf, d.FromDate, d.ToDate)
+  fmt.Fprintf(w, "%d added, %d removed, %d reweighted by more than %g points, %d unchanged.\n", len(d.Added), len(d.Removed), len(d.Reweighted), d.Threshold, d.Unchanged)
+  sections := []struct {
+    title string
+    changes []HoldingChange
+  } {
+    {"Added", d.Added},
+    {"Removed", d.Removed},
+    {"Reweighted", d.Reweighted},
+  }
+  for _, section := range sections {
+    if len(section.changes) == 0 {
+      continue
+    }
+    fmt.Fprintf(w, "\n### %s\n\n", section.title)
+    fmt.Fprintf(w, "| Name | Identifier | Old weight (%%) | New weight (%%) | Change |\n")
+    fmt.Fprintf(w, "|---|---|---:|---:|---:|\n")
+    for _, c := range section.changes {
+      id := fmt.Sprintf("%s %s", c.IdType, c.Id)
+      if c.PreviousId != "" {
+        id += fmt.Sprintf(" (was %s)", c.PreviousId)
+      }
+      fmt.Fprintf(w, "| %s | %s | %.4f | %.4f | %+.4f |\n", markdownCell(c.Name), markdownCell(id), c.OldWeight, c.NewWeight, c.weightChange())
+    }
+  }
+}
+
+func writeDiff(w io.Writer, format string, d FilingDiff) error {
+  switch format {
+    case "text":
+      writeDiffText(w, d)
+    case "markdown":
+      writeDiffMarkdown(w, d)
+    case "json":
+      bytes, err := marshalDataJson(d)
+      if err != nil {
+        return err
+      }
+      _, err = w.Write(bytes)
+      return err
+    default:
+      return fmt.Errorf("unknown format %s, expected text, json or markdown", format)
+  }
+  return nil
+}
+
+// runDiff reports the changes of the components of an ETF between two filings.
+func runDiff(args []string) error {
+  flags := flag.NewFlagSet("diff", flag.ExitOnError)
+  etfFlag := flags.String("etf", "", "ETF to compare the filings of")
+  fromFlag := flags.String("from", "", "Date of the older filing (YYYY-MM-DD). Defaults to the second newest filing")
+  toFlag := flags.String("to", "", "Date of the newer filing (YYYY-MM-DD). Defaults to the newest filing")
+  thresholdFlag := flags.Float64("threshold", 0.01, "Minimum change of weight to report, in points")
+  formatFlag := flags.String("format", "text", "Output format: text, json or markdown")
+  flags.Parse(args)
+  if *etfFlag == "" {
+    return fmt.Errorf("-etf is required")
+  }
+  if *thresholdFlag < 0 {
+    return fmt.Errorf("-threshold must be positive")
+  }
+
+  indexes, err := readAllIndexes(*etfFlag)
+  if err != nil {
+    return fmt.Errorf("reading the filings of %s: %w", *etfFlag, err)
+  }
+  from, to, err := selectDiffFilings(*etfFlag, indexes, *fromFlag, *toFlag)
+  if err != nil {
+    return err
+  }
+  return writeDiff(os.Stdout, *formatFlag, diffFilings(*etfFlag, from, to, *thresholdFlag))
+}
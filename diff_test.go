@@ -0,0 +1,180 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestMatchComponents(t *testing.T) {
+  stock := IndexComponent{Name: "Apple Inc", Id: "US0378331005", IdType: "isin", Weight: 3}
+  otherStock := IndexComponent{Name: "Microsoft Corp", Id: "US5949181045", IdType: "isin", Weight: 2}
+  synthetic := IndexComponent{Name: "Private Placement", Id: "SYNE27165CA73B63C28", IdType: kSyntheticIdType, Weight: 1}
+  identified := IndexComponent{Name: "PRIVATE  placement", Id: "US0000000001", IdType: "cusip", Weight: 1}
+  strip := func (id, maturityDate string) IndexComponent {
+    return IndexComponent{Name: "United States Treasury Strip Coupon", Id: id, IdType: "isin", Weight: 1, Debt: &DebtInfo{MaturityDate: maturityDate, CouponKind: "none"}}
+  }
+  renamed := stock
+  renamed.Id = "US0378331006"
+  bond := strip("SYN1", "2030-01-01")
+  bond.IdType = kSyntheticIdType
+
+  tt := []struct {
+    name string
+    from []IndexComponent
+    to []IndexComponent
+    expected []int
+  } {
+    {"By id", []IndexComponent{stock, otherStock}, []IndexComponent{otherStock, stock}, []int{1, 0}},
+    {"Duplicated id", []IndexComponent{stock, stock}, []IndexComponent{stock, stock, stock}, []int{0, 1, -1}},
+    {"Name fallback", []IndexComponent{synthetic}, []IndexComponent{identified}, []int{0}},
+    {"Different ids of the same type", []IndexComponent{stock}, []IndexComponent{renamed}, []int{-1}},
+    {"Ambiguous name", []IndexComponent{synthetic, synthetic}, []IndexComponent{identified}, []int{-1}},
+    {"Different maturity", []IndexComponent{bond}, []IndexComponent{strip("US912834PZ59", "2031-01-01")}, []int{-1}},
+    {"Same maturity", []IndexComponent{bond}, []IndexComponent{strip("US912834PZ59", "2030-01-01")}, []int{0}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      if actual := matchComponents(tc.from, tc.to); !reflect.DeepEqual(actual, tc.expected) {
+        t.Errorf("Mismatched matches, expected=%v but got=%v", tc.expected, actual)
+      }
+    })
+  }
+}
+
+func diffTestFilings() (Index, Index) {
+  from := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2024-10-01", Components: []IndexComponent{
+    IndexComponent{Name: "Apple Inc", Id: "US0378331005", IdType: "isin", Weight: 5},
+    IndexComponent{Name: "Microsoft Corp", Id: "US5949181045", IdType: "isin", Weight: 4},
+    IndexComponent{Name: "Private | Placement", Id: "SYNE27165CA73B63C28", IdType: kSyntheticIdType, Weight: 2},
+    IndexComponent{Name: "Exxon Mobil Corp", Id: "US30231G1022", IdType: "isin", Weight: 1.5},
+  }}
+  to := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{
+    IndexComponent{Name: "Microsoft Corp", Id: "US5949181045", IdType: "isin", Weight: 6},
+    IndexComponent{Name: "Apple Inc", Id: "US0378331005", IdType: "isin", Weight: 4.995},
+    IndexComponent{Name: "Private | Placement", Id: "US0000000001", IdType: "cusip", Weight: 1.6},
+    IndexComponent{Name: "Nvidia Corp", Id: "US67066G1040", IdType: "isin", Weight: 1},
+  }}
+  return from, to
+}
+
+func TestDiffFilings(t *testing.T) {
+  from, to := diffTestFilings()
+  expected := FilingDiff{
+    Etf: "VXF",
+    FromDate: "2024-10-01",
+    ToDate: kDate,
+    Threshold: 0.01,
+    Added: []HoldingChange{HoldingChange{Kind: kHoldingAdded, Name: "Nvidia Corp", Id: "US67066G1040", IdType: "isin", NewWeight: 1}},
+    Removed: []HoldingChange{HoldingChange{Kind: kHoldingRemoved, Name: "Exxon Mobil Corp", Id: "US30231G1022", IdType: "isin", OldWeight: 1.5}},
+    Reweighted: []HoldingChange{
+      HoldingChange{Kind: kHoldingReweighted, Name: "Microsoft Corp", Id: "US5949181045", IdType: "isin", OldWeight: 4, NewWeight: 6},
+      HoldingChange{Kind: kHoldingReweighted, Name: "Private | Placement", Id: "US0000000001", IdType: "cusip", PreviousId: "SYNE27165CA73B63C28", OldWeight: 2, NewWeight: 1.6},
+    },
+    Unchanged: 1,
+  }
+  if actual := diffFilings("VXF", from, to, 0.01); !reflect.DeepEqual(actual, expected) {
+    t.Errorf("Mismatched diff, expected=%+v but got=%+v", expected, actual)
+    return
+  }
+
+  // 2 -> 1.6 is exactly at the threshold.
+  if actual := diffFilings("VXF", from, to, 0.4); len(actual.Reweighted) != 1 || actual.Unchanged != 2 {
+    t.Errorf("Expected 1 reweighted and 2 unchanged components but got %+v", actual)
+  }
+}
+
+func TestSelectDiffFilings(t *testing.T) {
+  indexes := []Index{Index{FilingDate: "2025-03-01"}, Index{FilingDate: "2025-02-01"}, Index{FilingDate: kDate}}
+  tt := []struct {
+    name string
+    indexes []Index
+    fromDate string
+    toDate string
+    // Empty on error.
+    expectedFrom string
+    expectedTo string
+  } {
+    {"Two newest", indexes, "", "", "2025-02-01", "2025-03-01"},
+    {"Given dates", indexes, kDate, "2025-03-01", kDate, "2025-03-01"},
+    {"Single filing", indexes[:1], "", "", "", ""},
+    {"Missing -to", indexes, kDate, "", "", ""},
+    {"Unknown date", indexes, "2024-01-01", "2025-03-01", "", ""},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      from, to, err := selectDiffFilings("VXF", tc.indexes, tc.fromDate, tc.toDate)
+      if tc.expectedFrom == "" {
+        if err == nil {
+          t.Errorf("Expected an error but got %s -> %s", from.FilingDate, to.FilingDate)
+        }
+        return
+      }
+      if err != nil || from.FilingDate != tc.expectedFrom || to.FilingDate != tc.expectedTo {
+        t.Errorf("Expected %s -> %s but got %s -> %s (err=%+v)", tc.expectedFrom, tc.expectedTo, from.FilingDate, to.FilingDate, err)
+      }
+    })
+  }
+}
+
+func TestWriteDiff(t *testing.T) {
+  from, to := diffTestFilings()
+  d := diffFilings("VXF", from, to, 0.01)
+  tt := []struct {
+    format string
+    // Lines expected in the output.
+    expected []string
+  } {
+    {"text", []string{
+      "VXF: 2024-10-01 -> 2025-01-01 (weight changes above 0.01 points)",
+      "  +    1.0000%  Nvidia Corp (isin US67066G1040)",
+      "  -    1.5000%  Exxon Mobil Corp (isin US30231G1022)",
+      "       2.0000% ->    1.6000% (-0.4000)  Private | Placement (cusip US0000000001), previously SYNE27165CA73B63C28",
+      "Unchanged: 1",
+    }},
+    {"markdown", []string{
+      "## VXF: 2024-10-01 → 2025-01-01",
+      "1 added, 1 removed, 2 reweighted by more than 0.01 points, 1 unchanged.",
+      "### Reweighted",
+      "| Microsoft Corp | isin US5949181045 | 4.0000 | 6.0000 | +2.0000 |",
+      "| Private \\| Placement | cusip US0000000001 (was SYNE27165CA73B63C28) | 2.0000 | 1.6000 | -0.4000 |",
+    }},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.format, func (t *testing.T) {
+      out := bytes.Buffer{}
+      if err := writeDiff(&out, tc.format, d); err != nil {
+        t.Errorf("Failed to write the diff (err=%+v)", err)
+        return
+      }
+      lines := strings.Split(out.String(), "\n")
+      for _, line := range tc.expected {
+        if !strings.Contains(out.String(), line + "\n") {
+          t.Errorf("Missing line %q in:\n%s", line, strings.Join(lines, "\n"))
+          return
+        }
+      }
+    })
+  }
+
+  t.Run("json", func (t *testing.T) {
+    out := bytes.Buffer{}
+    if err := writeDiff(&out, "json", d); err != nil {
+      t.Errorf("Failed to write the diff (err=%+v)", err)
+      return
+    }
+    actual := FilingDiff{}
+    if err := json.Unmarshal(out.Bytes(), &actual); err != nil || !reflect.DeepEqual(actual, d) {
+      t.Errorf("Mismatched diff %+v (err=%+v)", actual, err)
+    }
+  })
+
+  if err := writeDiff(&bytes.Buffer{}, "yaml", d); err == nil {
+    t.Errorf("Expected an unknown format to be rejected")
+  }
+}
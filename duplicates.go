@@ -0,0 +1,68 @@
+package main
+
+// If set, the components reported on several lines (e.g. separate lots or
+// restricted vs unrestricted shares) are merged into a single component.
+var aggregateDuplicates bool
+
+// componentKey identifies a security: the same identifier can be used by securities of different types
+// (e.g. a CUSIP and a "faid").
+func componentKey(component IndexComponent) string {
+  return component.IdType + "/" + component.Id
+}
+
+// mergeLending returns the lending of a component made of the lines lent as `a` and `b` (either can be nil).
+func mergeLending(a, b *LendingInfo) *LendingInfo {
+  if a == nil || b == nil {
+    if a == nil {
+      return b
+    }
+    return a
+  }
+  return &LendingInfo{
+    OnLoan: a.OnLoan || b.OnLoan,
+    LoanValue: a.LoanValue + b.LoanValue,
+    CashCollateral: a.CashCollateral || b.CashCollateral,
+    CashCollateralValue: a.CashCollateralValue + b.CashCollateralValue,
+    NonCashCollateral: a.NonCashCollateral || b.NonCashCollateral,
+    NonCashCollateralValue: a.NonCashCollateralValue + b.NonCashCollateralValue,
+  }
+}
+
+// aggregateDuplicateComponents merges the components with the same identifier type and identifier, summing
+// their weights and lending values. The other fields are taken from the first line. The components' `Lots`
+// are expected to contain their source line and are only kept for the merged components.
+func aggregateDuplicateComponents(components []IndexComponent) []IndexComponent {
+  res := []IndexComponent{}
+  positions := map[string]int{}
+  for _, component := range components {
+    pos, ok := positions[componentKey(component)]
+    if !ok {
+      positions[componentKey(component)] = len(res)
+      res = append(res, component)
+      continue
+    }
+    res[pos].Weight += component.Weight
+    res[pos].Lending = mergeLending(res[pos].Lending, component.Lending)
+    res[pos].Lots = append(res[pos].Lots, component.Lots...)
+  }
+  for i := range res {
+    if len(res[i].Lots) <= 1 {
+      res[i].Lots = nil
+    }
+  }
+  return res
+}
+
+// duplicateIds returns the identifiers (as "<id_type>/<id>") reported by more than one component, with their count.
+func duplicateIds(index Index) map[string]int {
+  counts := map[string]int{}
+  for _, component := range index.Components {
+    counts[componentKey(component)]++
+  }
+  for id, count := range counts {
+    if count <= 1 {
+      delete(counts, id)
+    }
+  }
+  return counts
+}
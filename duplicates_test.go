@@ -0,0 +1,96 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "reflect"
+  "testing"
+)
+
+// Same security reported on 2 lines, with a different security in between.
+const kDuplicatedInvstOrSecsXml = `<invstOrSec><name>Barrick Mining Corp</name><identifiers><isin value="CA06849F1080"/></identifiers><pctVal>0.1</pctVal></invstOrSec>` +
+  `<invstOrSec><name>Eli Lilly &amp; Co</name><identifiers><isin value="US5324571083"/></identifiers><pctVal>1.5</pctVal></invstOrSec>` +
+  `<invstOrSec><name>Barrick Mining Corp (restricted)</name><identifiers><isin value="CA06849F1080"/></identifiers><pctVal>0.05</pctVal></invstOrSec>`
+
+func TestAggregateDuplicates(t *testing.T) {
+  tt := []struct {
+    name string
+    aggregate bool
+    expectedCount int
+    expectedBarrickWeight float32
+    expectedBarrickLots []ComponentLot
+  } {
+    {"Duplicates are kept by default", false, 3, 0.1, nil},
+    {"Duplicates are merged when aggregating", true, 2, 0.15, []ComponentLot{ComponentLot{Line: 1, Name: "Barrick Mining Corp", Weight: 0.1}, ComponentLot{Line: 3, Name: "Barrick Mining Corp (restricted)", Weight: 0.05}}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      aggregateDuplicates = tc.aggregate
+      defer func () { aggregateDuplicates = false }()
+
+      payload := fmt.Sprintf(`<edgarSubmission><formData><genInfo><seriesName>VANGUARD TOTAL INTERNATIONAL STOCK INDEX FUND</seriesName><seriesId>S000002925</seriesId></genInfo><invstOrSecs>%s</invstOrSecs></formData></edgarSubmission>`, kDuplicatedInvstOrSecsXml)
+      submission := singleSubmission{}
+      err := xml.Unmarshal([]byte(payload), &submission)
+      if err != nil {
+        panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", payload, err))
+      }
+      index := populateIndexFromSingleSubmission(submission, SubmissionInfo{kCik, kAccessionNumber, kSubmissionDate})
+      if len(index.Components) != tc.expectedCount {
+        t.Errorf("Expected %d components but got %d (index=%+v)", tc.expectedCount, len(index.Components), index)
+        return
+      }
+      // The components are sorted by decreasing weight, so Barrick is second.
+      barrick := index.Components[1]
+      if barrick.Id != "CA06849F1080" || !almostEqual(barrick.Weight, tc.expectedBarrickWeight) {
+        t.Errorf("Mismatched component, expected weight=%f but got=%+v", tc.expectedBarrickWeight, barrick)
+        return
+      }
+      if fmt.Sprintf("%+v", barrick.Lots) != fmt.Sprintf("%+v", tc.expectedBarrickLots) {
+        t.Errorf("Mismatched lots, expected=%+v but got=%+v", tc.expectedBarrickLots, barrick.Lots)
+        return
+      }
+      if index.Components[0].Lots != nil {
+        t.Errorf("Expected no lots for a single line component but got %+v", index.Components[0].Lots)
+        return
+      }
+      if index.AggregatedDuplicates != tc.aggregate {
+        t.Errorf("Expected aggregated_duplicates=%t but got %t", tc.aggregate, index.AggregatedDuplicates)
+        return
+      }
+    })
+  }
+}
+
+func TestAggregateDuplicateComponents(t *testing.T) {
+  lot := func (id, idType string, line int, weight float32, lending *LendingInfo) IndexComponent {
+    return IndexComponent{Name: "Security", Id: id, IdType: idType, Weight: weight, Lending: lending, Lots: []ComponentLot{ComponentLot{Line: line, Name: "Security", Weight: weight}}}
+  }
+  tt := []struct {
+    name string
+    components []IndexComponent
+    expected []IndexComponent
+  } {
+    {"Same id of different types", []IndexComponent{lot("123456789", "cusip", 1, 1, nil), lot("123456789", "faid", 2, 2, nil)}, []IndexComponent{
+      IndexComponent{Name: "Security", Id: "123456789", IdType: "cusip", Weight: 1},
+      IndexComponent{Name: "Security", Id: "123456789", IdType: "faid", Weight: 2},
+    }},
+    {"Lending values are summed", []IndexComponent{
+      lot("US5324571083", "isin", 1, 1, &LendingInfo{OnLoan: true, LoanValue: 100, CashCollateral: true, CashCollateralValue: 110}),
+      lot("US5324571083", "isin", 2, 2, nil),
+      lot("US5324571083", "isin", 3, 3, &LendingInfo{OnLoan: true, LoanValue: 50, NonCashCollateral: true, NonCashCollateralValue: 60}),
+    }, []IndexComponent{
+      IndexComponent{Name: "Security", Id: "US5324571083", IdType: "isin", Weight: 6, Lending: &LendingInfo{OnLoan: true, LoanValue: 150, CashCollateral: true, CashCollateralValue: 110, NonCashCollateral: true, NonCashCollateralValue: 60}, Lots: []ComponentLot{
+        ComponentLot{Line: 1, Name: "Security", Weight: 1}, ComponentLot{Line: 2, Name: "Security", Weight: 2}, ComponentLot{Line: 3, Name: "Security", Weight: 3},
+      }},
+    }},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      if actual := aggregateDuplicateComponents(tc.components); !reflect.DeepEqual(actual, tc.expected) {
+        t.Errorf("Mismatched components, expected=%+v but got=%+v", tc.expected, actual)
+      }
+    })
+  }
+}
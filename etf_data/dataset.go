@@ -0,0 +1,232 @@
+// Package etf_data loads the data fetched by vanguard_etfs: the registry of the ETFs (all_etfs.json)
+// and the data/ tree, from disk or any fs.FS (e.g. an embed.FS).
+package etf_data
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "maps"
+  "os"
+  "path"
+  "slices"
+  "sync"
+)
+
+// Paths in the repository.
+const kRegistryFile = "all_etfs.json"
+const kDataDir = "data"
+
+var ErrUnknownEtf = errors.New("unknown ETF")
+// Returned by AsOf when the ETF has no filing on or before the date.
+var ErrNoFiling = errors.New("no filing")
+
+// Registry lists the ETFs (see StoredIndex) of each CIK.
+type Registry map[int][]StoredIndex
+
+// ReadRegistry reads all_etfs.json at the root of `fsys`.
+func ReadRegistry(fsys fs.FS) (Registry, error) {
+  res := Registry{}
+  if err := readJson(fsys, kRegistryFile, &res); err != nil {
+    return nil, err
+  }
+  return res, nil
+}
+
+// Ciks returns the CIKs, sorted.
+func (r Registry) Ciks() []int {
+  return slices.Sorted(maps.Keys(r))
+}
+
+// Etfs returns the ETFs of all the CIKs, sorted by name.
+func (r Registry) Etfs() []string {
+  res := []string{}
+  for _, etfs := range r {
+    for _, etf := range etfs {
+      res = append(res, etf.Name)
+    }
+  }
+  slices.Sort(res)
+  return res
+}
+
+func (r Registry) hasEtf(name string) bool {
+  for _, etfs := range r {
+    for _, etf := range etfs {
+      if etf.Name == name {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func readJson(fsys fs.FS, name string, v any) error {
+  bytes, err := fs.ReadFile(fsys, name)
+  if err != nil {
+    return err
+  }
+  if err := json.Unmarshal(bytes, v); err != nil {
+    return fmt.Errorf("decoding %s: %w", name, err)
+  }
+  return nil
+}
+
+// HistoryPaths returns the paths of the history files of `etf` in the data/ tree, in full and delta-encoded
+// (see DeltaHistory) formats.
+func HistoryPaths(etf string) (full, delta string) {
+  return path.Join("all", etf + ".json"), path.Join("all", etf + ".delta.json")
+}
+
+// LatestPath returns the path of the latest filing of `etf` in the data/ tree.
+func LatestPath(etf string) string {
+  return path.Join("latest", etf + ".json")
+}
+
+// HistoryFile returns the path of the history file of `etf` in `dataFs` (the data/ tree) and whether it's
+// delta-encoded. The delta-encoded file takes precedence if both exist.
+func HistoryFile(dataFs fs.FS, etf string) (string, bool) {
+  full, delta := HistoryPaths(etf)
+  if _, err := fs.Stat(dataFs, delta); err == nil {
+    return delta, true
+  }
+  return full, false
+}
+
+// ReadHistory returns the filings of `etf` stored in `dataFs` (the data/ tree) in either format, ordered from
+// the newest to the oldest.
+func ReadHistory(dataFs fs.FS, etf string) ([]Index, error) {
+  name, delta := HistoryFile(dataFs, etf)
+  bytes, err := fs.ReadFile(dataFs, name)
+  if err != nil {
+    return nil, err
+  }
+  indexes, err := DecodeHistory(bytes, delta)
+  if err != nil {
+    return nil, fmt.Errorf("decoding %s: %w", name, err)
+  }
+  return indexes, nil
+}
+
+// Holding is a component of the latest filing of an ETF.
+type Holding struct {
+  Etf string
+  FilingDate string
+  Component IndexComponent
+}
+
+// Dataset gives access to the filings of the ETFs in the registry. It's safe for concurrent use.
+type Dataset struct {
+  registry Registry
+  dataFs fs.FS
+
+  mutex sync.Mutex
+  // Histories already read, by ETF.
+  histories map[string][]Index
+}
+
+// Open loads the dataset from `fsys`, which has the layout of the repository: all_etfs.json and the data/
+// directory at its root. For instance, with `//go:embed all_etfs.json data/all data/latest`.
+func Open(fsys fs.FS) (*Dataset, error) {
+  registry, err := ReadRegistry(fsys)
+  if err != nil {
+    return nil, err
+  }
+  dataFs, err := fs.Sub(fsys, kDataDir)
+  if err != nil {
+    return nil, err
+  }
+  return &Dataset{registry: registry, dataFs: dataFs, histories: map[string][]Index{}}, nil
+}
+
+// OpenDir loads the dataset from a checkout of the repository.
+func OpenDir(dir string) (*Dataset, error) {
+  return Open(os.DirFS(dir))
+}
+
+func (d *Dataset) Registry() Registry {
+  return d.registry
+}
+
+func (d *Dataset) checkEtf(etf string) error {
+  if !d.registry.hasEtf(etf) {
+    return fmt.Errorf("%w %s", ErrUnknownEtf, etf)
+  }
+  return nil
+}
+
+// Latest returns the newest filing of `etf` (data/latest/<ETF>.json).
+func (d *Dataset) Latest(etf string) (Index, error) {
+  res := Index{}
+  if err := d.checkEtf(etf); err != nil {
+    return res, err
+  }
+  err := readJson(d.dataFs, LatestPath(etf), &res)
+  return res, err
+}
+
+// History returns the filings of `etf`, ordered from the newest to the oldest. The filings are read once
+// and shared between the calls, so they must not be modified.
+func (d *Dataset) History(etf string) ([]Index, error) {
+  if err := d.checkEtf(etf); err != nil {
+    return nil, err
+  }
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+  if indexes, ok := d.histories[etf]; ok {
+    return indexes, nil
+  }
+  indexes, err := ReadHistory(d.dataFs, etf)
+  if err != nil {
+    return nil, err
+  }
+  d.histories[etf] = indexes
+  return indexes, nil
+}
+
+// AsOf returns the newest filing of `etf` filed on or before `date` (YYYY-MM-DD).
+func (d *Dataset) AsOf(etf string, date string) (Index, error) {
+  indexes, err := d.History(etf)
+  if err != nil {
+    return Index{}, err
+  }
+  for _, index := range indexes {
+    if index.FilingDate <= date {
+      return index, nil
+    }
+  }
+  return Index{}, fmt.Errorf("%w for %s on or before %s", ErrNoFiling, etf, date)
+}
+
+// Performance returns the monthly performance of `etf` (data/performance/<ETF>.json), ordered from the
+// newest to the oldest month.
+func (d *Dataset) Performance(etf string) ([]MonthlyPerformance, error) {
+  if err := d.checkEtf(etf); err != nil {
+    return nil, err
+  }
+  res := []MonthlyPerformance{}
+  err := readJson(d.dataFs, path.Join("performance", etf + ".json"), &res)
+  return res, err
+}
+
+// Holders returns the ETFs whose latest filing holds the security `securityId` (its identifier, of any type),
+// sorted by ETF. The ETFs without stored filings are skipped.
+func (d *Dataset) Holders(securityId string) ([]Holding, error) {
+  res := []Holding{}
+  for _, etf := range d.registry.Etfs() {
+    index, err := d.Latest(etf)
+    if errors.Is(err, fs.ErrNotExist) {
+      continue
+    }
+    if err != nil {
+      return nil, err
+    }
+    for _, component := range index.Components {
+      if component.Id == securityId {
+        res = append(res, Holding{etf, index.FilingDate, component})
+      }
+    }
+  }
+  return res, nil
+}
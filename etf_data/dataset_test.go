@@ -0,0 +1,162 @@
+package etf_data
+
+import (
+  "errors"
+  "io/fs"
+  "reflect"
+  "testing"
+  "testing/fstest"
+)
+
+const kRegistry = `{"36405": [{"series_id": "S000002841", "name": "VXF"}, {"series_id": "S000002848", "name": "VTI"}], "52848": [{"series_id": "S000012345", "name": "VEXC"}]}`
+
+// The history of VXF, from the newest to the oldest.
+const kVxfHistory = `[
+  {"schema_version": 1, "name": "Extended Market Index", "series_id": "S000002841", "filing_date": "2025-02-01", "components": [{"name": "Apple Inc", "id": "US0378331005", "id_type": "isin", "weight": 3}]},
+  {"schema_version": 1, "name": "Extended Market Index", "series_id": "S000002841", "filing_date": "2025-01-01", "components": [{"name": "Eli Lilly & Co", "id": "US5324571083", "id_type": "isin", "weight": 1.5}]}
+]`
+
+// The same filings for VTI, delta-encoded, where Apple is reweighted.
+const kVtiDeltaHistory = `{"schema_version": 1,
+  "base": {"schema_version": 1, "name": "Total Stock Market Index", "series_id": "S000002848", "filing_date": "2025-01-01", "components": [{"name": "Apple Inc", "id": "US0378331005", "id_type": "isin", "weight": 6}]},
+  "deltas": [{"schema_version": 1, "name": "Total Stock Market Index", "series_id": "S000002848", "filing_date": "2025-02-01", "components": [{"previous": 0, "weight": 7}]}]
+}`
+
+const kVtiLatest = `{"schema_version": 1, "name": "Total Stock Market Index", "series_id": "S000002848", "filing_date": "2025-02-01", "components": [{"name": "Apple Inc", "id": "US0378331005", "id_type": "isin", "weight": 7}]}`
+
+func testDataset(t *testing.T) *Dataset {
+  fsys := fstest.MapFS{
+    "all_etfs.json": &fstest.MapFile{Data: []byte(kRegistry)},
+    "data/all/VXF.json": &fstest.MapFile{Data: []byte(kVxfHistory)},
+    "data/latest/VXF.json": &fstest.MapFile{Data: []byte(`{"filing_date": "2025-02-01", "components": [{"name": "Apple Inc", "id": "US0378331005", "id_type": "isin", "weight": 3}]}`)},
+    "data/all/VTI.delta.json": &fstest.MapFile{Data: []byte(kVtiDeltaHistory)},
+    "data/latest/VTI.json": &fstest.MapFile{Data: []byte(kVtiLatest)},
+    "data/performance/VTI.json": &fstest.MapFile{Data: []byte(`[{"month": "2025-01", "filing_date": "2025-02-01", "total_returns": {"C000007800": 1.5}}]`)},
+  }
+  d, err := Open(fsys)
+  if err != nil {
+    t.Fatalf("Failed to open the dataset (err=%+v)", err)
+  }
+  return d
+}
+
+func TestRegistry(t *testing.T) {
+  d := testDataset(t)
+  if ciks := d.Registry().Ciks(); !reflect.DeepEqual(ciks, []int{36405, 52848}) {
+    t.Errorf("Unexpected CIKs %v", ciks)
+  }
+  if etfs := d.Registry().Etfs(); !reflect.DeepEqual(etfs, []string{"VEXC", "VTI", "VXF"}) {
+    t.Errorf("Unexpected ETFs %v", etfs)
+  }
+}
+
+func TestHistory(t *testing.T) {
+  d := testDataset(t)
+  tt := []struct {
+    name string
+    etf string
+    expectedDates []string
+    expectedWeight float32
+    expectedErr error
+  } {
+    {"Full history", "VXF", []string{"2025-02-01", "2025-01-01"}, 3, nil},
+    {"Delta-encoded history", "VTI", []string{"2025-02-01", "2025-01-01"}, 7, nil},
+    {"No stored data", "VEXC", nil, 0, fs.ErrNotExist},
+    {"Unknown ETF", "VOO", nil, 0, ErrUnknownEtf},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      // Twice, the second time from the cache.
+      for range 2 {
+        indexes, err := d.History(tc.etf)
+        if tc.expectedErr != nil {
+          if !errors.Is(err, tc.expectedErr) {
+            t.Errorf("Expected err=%+v but got %+v", tc.expectedErr, err)
+          }
+          return
+        }
+        if err != nil {
+          t.Errorf("Failed to read the history (err=%+v)", err)
+          return
+        }
+        dates := []string{}
+        for _, index := range indexes {
+          dates = append(dates, index.FilingDate)
+        }
+        if !reflect.DeepEqual(dates, tc.expectedDates) || indexes[0].Components[0].Weight != tc.expectedWeight {
+          t.Errorf("Unexpected history %+v", indexes)
+          return
+        }
+      }
+    })
+  }
+}
+
+func TestAsOf(t *testing.T) {
+  d := testDataset(t)
+  tt := []struct {
+    name string
+    date string
+    expectedDate string
+    expectedErr error
+  } {
+    {"After the newest filing", "2025-06-30", "2025-02-01", nil},
+    {"On a filing date", "2025-01-01", "2025-01-01", nil},
+    {"Between filings", "2025-01-31", "2025-01-01", nil},
+    {"Before the oldest filing", "2024-12-31", "", ErrNoFiling},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      index, err := d.AsOf("VTI", tc.date)
+      if !errors.Is(err, tc.expectedErr) {
+        t.Errorf("Expected err=%+v but got %+v", tc.expectedErr, err)
+        return
+      }
+      if index.FilingDate != tc.expectedDate {
+        t.Errorf("Expected the filing of %s but got %+v", tc.expectedDate, index)
+      }
+    })
+  }
+}
+
+func TestLatestAndPerformance(t *testing.T) {
+  d := testDataset(t)
+  if index, err := d.Latest("VTI"); err != nil || index.FilingDate != "2025-02-01" || len(index.Components) != 1 {
+    t.Errorf("Unexpected latest filing %+v (err=%+v)", index, err)
+  }
+  if months, err := d.Performance("VTI"); err != nil || len(months) != 1 || months[0].TotalReturns["C000007800"] != 1.5 {
+    t.Errorf("Unexpected performance %+v (err=%+v)", months, err)
+  }
+}
+
+func TestHolders(t *testing.T) {
+  d := testDataset(t)
+  tt := []struct {
+    name string
+    id string
+    expectedEtfs []string
+  } {
+    {"Held by several ETFs", "US0378331005", []string{"VTI", "VXF"}},
+    {"Only in a past filing", "US5324571083", []string{}},
+    {"Unknown security", "US0000000000", []string{}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      holdings, err := d.Holders(tc.id)
+      if err != nil {
+        t.Errorf("Failed to find the holders (err=%+v)", err)
+        return
+      }
+      etfs := []string{}
+      for _, holding := range holdings {
+        etfs = append(etfs, holding.Etf)
+      }
+      if !reflect.DeepEqual(etfs, tc.expectedEtfs) {
+        t.Errorf("Expected the holders %v but got %+v", tc.expectedEtfs, holdings)
+      }
+    })
+  }
+}
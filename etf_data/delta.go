@@ -0,0 +1,79 @@
+package etf_data
+
+import (
+  "encoding/json"
+  "fmt"
+  "slices"
+)
+
+// DeltaHistory is the delta-encoded history of an ETF (`data/all/<ETF>.delta.json`): its oldest filing in
+// full followed by a delta per filing, from the oldest to the newest. A new filing only appends a delta.
+type DeltaHistory struct {
+  SchemaVersion int `json:"schema_version"`
+  Base Index `json:"base"`
+  Deltas []IndexDelta `json:"deltas"`
+}
+
+// IndexDelta is a filing whose components are encoded against the previous filing.
+// The components of the previous filing that aren't referenced were removed.
+type IndexDelta struct {
+  Index
+  // Shadows Index.Components, which is left empty.
+  Components []ComponentRef `json:"components"`
+}
+
+// ComponentRef is either a reference to a component of the previous filing, reweighted if Weight is set,
+// or a component added in full. A component whose fields other than the weight changed is added.
+type ComponentRef struct {
+  // Position in the previous filing.
+  Previous *int `json:"previous,omitempty"`
+  Weight *float32 `json:"weight,omitempty"`
+  Added *IndexComponent `json:"added,omitempty"`
+}
+
+// Decode reconstructs the indexes, ordered from the newest to the oldest.
+// The unchanged components share their nested objects (Debt, Lending and Lots) with the previous filing.
+func (h DeltaHistory) Decode() ([]Index, error) {
+  indexes := []Index{h.Base}
+  previous := h.Base
+  for i, delta := range h.Deltas {
+    index := delta.Index
+    index.Components = make([]IndexComponent, 0, len(delta.Components))
+    for _, ref := range delta.Components {
+      switch {
+        case ref.Added != nil:
+          index.Components = append(index.Components, *ref.Added)
+        case ref.Previous != nil:
+          position := *ref.Previous
+          if position < 0 || position >= len(previous.Components) {
+            return nil, fmt.Errorf("delta %d (%s) references component %d but the previous filing has %d", i, delta.FilingDate, position, len(previous.Components))
+          }
+          component := previous.Components[position]
+          if ref.Weight != nil {
+            component.Weight = *ref.Weight
+          }
+          index.Components = append(index.Components, component)
+        default:
+          return nil, fmt.Errorf("delta %d (%s) has an empty component", i, delta.FilingDate)
+      }
+    }
+    indexes = append(indexes, index)
+    previous = index
+  }
+  slices.Reverse(indexes)
+  return indexes, nil
+}
+
+// DecodeHistory decodes the content of a history file, delta-encoded or not (see HistoryFile).
+func DecodeHistory(bytes []byte, delta bool) ([]Index, error) {
+  if !delta {
+    indexes := []Index{}
+    err := json.Unmarshal(bytes, &indexes)
+    return indexes, err
+  }
+  h := DeltaHistory{}
+  if err := json.Unmarshal(bytes, &h); err != nil {
+    return nil, err
+  }
+  return h.Decode()
+}
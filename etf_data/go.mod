@@ -0,0 +1,3 @@
+module github.com/jchaffraix/vanguard_etfs/etf_data
+
+go 1.24.2
@@ -0,0 +1,191 @@
+package etf_data
+
+// Version of the format of the data files (`schema_version`), bumped on incompatible changes.
+const SchemaVersion = 1
+
+type IndexComponent struct {
+  Name string `json:"name"`
+  Id string `json:"id"`
+  IdType string `json:"id_type"`
+  Weight float32 `json:"weight"`
+  // Only present for debt securities (e.g. bonds).
+  Debt *DebtInfo `json:"debt,omitempty"`
+  // Only present for components involved in securities lending.
+  Lending *LendingInfo `json:"lending,omitempty"`
+  // Only present for components merged from several lines of the filing (see `-aggregate_duplicates`).
+  Lots []ComponentLot `json:"lots,omitempty"`
+}
+
+// Index is a filing of an ETF, as stored in data/latest and data/all.
+type Index struct {
+  // See SchemaVersion, set when writing the index.
+  SchemaVersion int `json:"schema_version"`
+  Name string `json:"name"`
+  SeriesId string `json:"series_id"`
+  FilingDate string `json:"filing_date"`
+  // Only present for the filings fetched after their introduction.
+  AccessionNumber string `json:"accession_number,omitempty"`
+  // Date of the end of the reporting period (`<repPdDate>`).
+  ReportDate string `json:"report_date,omitempty"`
+  // Set if the components reported on several lines were merged (see `-aggregate_duplicates`).
+  AggregatedDuplicates bool `json:"aggregated_duplicates,omitempty"`
+  // Note: The components may add up to more than 100%.
+  Components []IndexComponent `json:"components"`
+  // Reconciliation of the weights. Only present for the filings fetched after its introduction.
+  Weights *WeightTotals `json:"weights,omitempty"`
+  // Only present for indexes holding debt securities.
+  Bonds *BondAnalytics `json:"bonds,omitempty"`
+  // Only present for indexes lending some of their components.
+  Lending *FundLending `json:"lending,omitempty"`
+  // Only present for bond funds.
+  Risk *RiskMetrics `json:"risk,omitempty"`
+}
+
+// Reconciliation of an index's weights to 100%, in percent of the fund's net assets.
+type WeightTotals struct {
+  // Sum of the weights of the components.
+  Components float32 `json:"components"`
+  // Sum of the weights of the derivatives, which are removed from the components.
+  Derivatives float32 `json:"derivatives"`
+  // Sum of the weights of the cash and cash sweep (CMT) components, included in `Components`.
+  Cash float32 `json:"cash"`
+  // What's left to 100% after the components and the derivatives: the other assets and liabilities
+  // (e.g. receivables, payables or the liability to return the lending collateral).
+  Residual float32 `json:"residual"`
+}
+
+// A line of the filing that was merged into a component.
+type ComponentLot struct {
+  // 1-based position of the line in the filing's holdings.
+  Line int `json:"line"`
+  Name string `json:"name"`
+  Weight float32 `json:"weight"`
+}
+
+type DebtInfo struct {
+  // Format: YYYY-MM-DD.
+  MaturityDate string `json:"maturity_date"`
+  // One of "fixed", "floating", "variable" or "none".
+  CouponKind string `json:"coupon_kind"`
+  // Annualized rate, in percent.
+  CouponRate float32 `json:"coupon_rate"`
+  IsDefault bool `json:"is_default"`
+  InterestInArrears bool `json:"interest_in_arrears"`
+  IsPaidInKind bool `json:"is_paid_in_kind"`
+  Convertible *ConvertibleInfo `json:"convertible,omitempty"`
+}
+
+type ConvertibleInfo struct {
+  IsMandatory bool `json:"is_mandatory"`
+  IsContingent bool `json:"is_contingent"`
+  References []ConvertibleReference `json:"references"`
+}
+
+// The security that the bond converts to.
+type ConvertibleReference struct {
+  Name string `json:"name"`
+  Title string `json:"title"`
+  ConversionRatio float32 `json:"conversion_ratio"`
+  Currency string `json:"currency"`
+}
+
+type MaturityBucket struct {
+  // Bounds are in years from the filing date: [MinYears, MaxYears).
+  // MaxYears is 0 for the last, open-ended bucket.
+  MinYears int `json:"min_years"`
+  MaxYears int `json:"max_years"`
+  // Sum of the weights of the bonds in the bucket.
+  Weight float32 `json:"weight"`
+}
+
+type BondAnalytics struct {
+  // Sum of the weights of the components with debt information.
+  BondWeight float32 `json:"bond_weight"`
+  // In years from the filing date, weighted by the bonds' weights.
+  WeightedAverageMaturity float32 `json:"weighted_average_maturity"`
+  // In percent, weighted by the bonds' weights.
+  WeightedCoupon float32 `json:"weighted_coupon"`
+  MaturityBuckets []MaturityBucket `json:"maturity_buckets"`
+}
+
+type LendingInfo struct {
+  OnLoan bool `json:"on_loan"`
+  // Values are in USD and 0 if not reported.
+  LoanValue float64 `json:"loan_value"`
+  CashCollateral bool `json:"cash_collateral"`
+  CashCollateralValue float64 `json:"cash_collateral_value"`
+  NonCashCollateral bool `json:"non_cash_collateral"`
+  NonCashCollateralValue float64 `json:"non_cash_collateral_value"`
+}
+
+type Borrower struct {
+  Name string `json:"name"`
+  Lei string `json:"lei"`
+  // Aggregate value of the securities on loan to the borrower, in USD.
+  Value float64 `json:"value"`
+}
+
+type FundLending struct {
+  // Percentage of the fund's net assets on loan, computed as described by PctOnLoanBasis.
+  PctOnLoan float32 `json:"pct_on_loan"`
+  // Either "net_assets" (the loan values over the net assets) or "weights" (the sum of the weights of the
+  // components on loan, when the net assets or the loan values aren't reported).
+  PctOnLoanBasis string `json:"pct_on_loan_basis"`
+  ComponentsOnLoan int `json:"components_on_loan"`
+  Borrowers []Borrower `json:"borrowers"`
+}
+
+// In USD, per maturity bucket.
+type MaturityRisk struct {
+  ThreeMonths float64 `json:"3m"`
+  OneYear float64 `json:"1y"`
+  FiveYears float64 `json:"5y"`
+  TenYears float64 `json:"10y"`
+  ThirtyYears float64 `json:"30y"`
+}
+
+type CurrencyRisk struct {
+  Currency string `json:"currency"`
+  // Change in value for a 1 basis point change in interest rates.
+  Dv01 MaturityRisk `json:"dv01"`
+  // Change in value for a 100 basis points change in interest rates.
+  Dv100 MaturityRisk `json:"dv100"`
+}
+
+type RiskMetrics struct {
+  InterestRate []CurrencyRisk `json:"interest_rate"`
+  // Change in value for a 1 basis point change in credit spreads.
+  CreditSpreadInvestmentGrade MaturityRisk `json:"credit_spread_investment_grade"`
+  CreditSpreadNonInvestmentGrade MaturityRisk `json:"credit_spread_non_investment_grade"`
+}
+
+type Gains struct {
+  // In USD.
+  NetRealizedGain float64 `json:"net_realized_gain"`
+  NetUnrealizedAppreciation float64 `json:"net_unrealized_appreciation"`
+}
+
+type Flows struct {
+  // In USD.
+  Sales float64 `json:"sales"`
+  Reinvestments float64 `json:"reinvestments"`
+  Redemptions float64 `json:"redemptions"`
+}
+
+type MonthlyPerformance struct {
+  // Format: YYYY-MM.
+  Month string `json:"month"`
+  // The filing the month comes from.
+  FilingDate string `json:"filing_date"`
+  // Total returns in percent, keyed by share class ID.
+  TotalReturns map[string]float32 `json:"total_returns"`
+  // Keyed by asset category (e.g. "EQ" for equity contracts), or "non_derivative".
+  Gains map[string]Gains `json:"gains"`
+  Flows Flows `json:"flows"`
+}
+
+// StoredIndex is an ETF of the registry (all_etfs.json).
+type StoredIndex struct {
+  SeriesId string `json:"series_id"`
+  Name string `json:"name"`
+}
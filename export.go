@@ -0,0 +1,209 @@
+package main
+
+import (
+  "bufio"
+  "encoding/csv"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+)
+
+// holdingWriter writes the holdings, one row per ETF × filing date × component.
+type holdingWriter interface {
+  writeHolding(etf string, index Index, component IndexComponent) error
+  // Flushes the buffered rows. The writer can't be used afterwards.
+  close() error
+}
+
+// Columns of the CSV export. The optional fields are empty when absent and the lists are JSON-encoded.
+var kCsvColumns = []string{
+  "etf", "series_id", "filing_date", "name", "id", "id_type", "weight",
+  "debt_maturity_date", "debt_coupon_kind", "debt_coupon_rate", "debt_is_default", "debt_interest_in_arrears", "debt_is_paid_in_kind",
+  "debt_convertible_is_mandatory", "debt_convertible_is_contingent", "debt_convertible_references",
+  "lending_on_loan", "lending_loan_value", "lending_cash_collateral", "lending_cash_collateral_value", "lending_non_cash_collateral", "lending_non_cash_collateral_value",
+  "lots",
+}
+
+type csvHoldingWriter struct {
+  w *csv.Writer
+}
+
+func newCsvHoldingWriter(w io.Writer) (*csvHoldingWriter, error) {
+  res := &csvHoldingWriter{csv.NewWriter(w)}
+  if err := res.w.Write(kCsvColumns); err != nil {
+    return nil, err
+  }
+  return res, nil
+}
+
+func formatFloat32(f float32) string {
+  return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func formatFloat64(f float64) string {
+  return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// formatJson encodes `v` for a CSV cell. Unlike json.Marshal, it doesn't escape "&", "<" and ">".
+func formatJson(v any) (string, error) {
+  buffer := strings.Builder{}
+  encoder := json.NewEncoder(&buffer)
+  encoder.SetEscapeHTML(false)
+  if err := encoder.Encode(v); err != nil {
+    return "", err
+  }
+  return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+func (c *csvHoldingWriter) writeHolding(etf string, index Index, component IndexComponent) error {
+  row := []string{etf, index.SeriesId, index.FilingDate, component.Name, component.Id, component.IdType, formatFloat32(component.Weight)}
+
+  debt := make([]string, 9)
+  if d := component.Debt; d != nil {
+    copy(debt, []string{d.MaturityDate, d.CouponKind, formatFloat32(d.CouponRate), strconv.FormatBool(d.IsDefault), strconv.FormatBool(d.InterestInArrears), strconv.FormatBool(d.IsPaidInKind)})
+    if d.Convertible != nil {
+      references, err := formatJson(d.Convertible.References)
+      if err != nil {
+        return err
+      }
+      copy(debt[6:], []string{strconv.FormatBool(d.Convertible.IsMandatory), strconv.FormatBool(d.Convertible.IsContingent), references})
+    }
+  }
+  row = append(row, debt...)
+
+  lending := make([]string, 6)
+  if l := component.Lending; l != nil {
+    lending = []string{strconv.FormatBool(l.OnLoan), formatFloat64(l.LoanValue), strconv.FormatBool(l.CashCollateral), formatFloat64(l.CashCollateralValue), strconv.FormatBool(l.NonCashCollateral), formatFloat64(l.NonCashCollateralValue)}
+  }
+  row = append(row, lending...)
+
+  lots := ""
+  if component.Lots != nil {
+    var err error
+    if lots, err = formatJson(component.Lots); err != nil {
+      return err
+    }
+  }
+  row = append(row, lots)
+  return c.w.Write(row)
+}
+
+func (c *csvHoldingWriter) close() error {
+  c.w.Flush()
+  return c.w.Error()
+}
+
+// A line of the NDJSON export: the component's fields along with its filing.
+type ndjsonHolding struct {
+  Etf string `json:"etf"`
+  SeriesId string `json:"series_id"`
+  FilingDate string `json:"filing_date"`
+  IndexComponent
+}
+
+type ndjsonHoldingWriter struct {
+  w *bufio.Writer
+  encoder *json.Encoder
+}
+
+func newNdjsonHoldingWriter(w io.Writer) *ndjsonHoldingWriter {
+  buffered := bufio.NewWriter(w)
+  encoder := json.NewEncoder(buffered)
+  encoder.SetEscapeHTML(false)
+  return &ndjsonHoldingWriter{buffered, encoder}
+}
+
+func (n *ndjsonHoldingWriter) writeHolding(etf string, index Index, component IndexComponent) error {
+  // Encode terminates each value with a newline.
+  return n.encoder.Encode(ndjsonHolding{etf, index.SeriesId, index.FilingDate, component})
+}
+
+func (n *ndjsonHoldingWriter) close() error {
+  return n.w.Flush()
+}
+
+// exportIndexes writes the holdings of the indexes of `etf` filed in [from, to].
+// Empty bounds are open.
+func exportIndexes(w holdingWriter, etf string, indexes []Index, from, to string) error {
+  for _, index := range indexes {
+    if (from != "" && index.FilingDate < from) || (to != "" && index.FilingDate > to) {
+      continue
+    }
+    for _, component := range index.Components {
+      if err := w.writeHolding(etf, index, component); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+// runExport exports the holdings history. The ETFs are read one at a time so that the whole history
+// doesn't have to fit in memory.
+func runExport(args []string) error {
+  flags := flag.NewFlagSet("export", flag.ExitOnError)
+  formatFlag := flags.String("format", "csv", "Output format: csv, ndjson or parquet")
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to export. Defaults to all ETFs")
+  fromFlag := flags.String("from", "", "Only export the filings on or after this date (YYYY-MM-DD)")
+  toFlag := flags.String("to", "", "Only export the filings on or before this date (YYYY-MM-DD)")
+  outputFlag := flags.String("output", "", "Path of the output file, or directory for parquet. Defaults to the standard output")
+  extendedFlag := flags.Bool("extended", false, "Add the optional debt, lending and lots columns to the parquet files")
+  flags.Parse(args)
+
+  out := io.Writer(os.Stdout)
+  var f *os.File
+  if *formatFlag == "parquet" && *outputFlag == "" {
+    return fmt.Errorf("the parquet format needs an output directory")
+  }
+  if *outputFlag != "" && *formatFlag != "parquet" {
+    var err error
+    if f, err = os.Create(*outputFlag); err != nil {
+      return err
+    }
+    defer f.Close() // ignore error; only for the early returns
+    out = f
+  }
+
+  var w holdingWriter
+  switch *formatFlag {
+    case "csv":
+      csvWriter, err := newCsvHoldingWriter(out)
+      if err != nil {
+        return err
+      }
+      w = csvWriter
+    case "ndjson":
+      w = newNdjsonHoldingWriter(out)
+    case "parquet":
+      parquetWriter, err := newParquetHoldingWriter(*outputFlag, *extendedFlag)
+      if err != nil {
+        return err
+      }
+      w = parquetWriter
+    default:
+      return fmt.Errorf("unknown format %s, expected csv, ndjson or parquet", *formatFlag)
+  }
+
+  for _, etf := range selectEtfs(*etfsFlag) {
+    indexes, err := readAllIndexes(etf)
+    if err != nil {
+      // The output may be the standard output so we report on the standard error.
+      fmt.Fprintf(os.Stderr, "Skipping %s as its file couldn't be read (err=%+v)\n", etf, err)
+      continue
+    }
+    if err := exportIndexes(w, etf, indexes, *fromFlag, *toFlag); err != nil {
+      return err
+    }
+  }
+  if err := w.close(); err != nil {
+    return err
+  }
+  if f != nil {
+    return f.Close()
+  }
+  return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/csv"
+  "encoding/json"
+  "reflect"
+  "testing"
+)
+
+func exportTestIndexes() []Index {
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 2.0219882, Debt: &DebtInfo{MaturityDate: "2050-02-15", CouponKind: "none", Convertible: &ConvertibleInfo{IsMandatory: true, IsContingent: false, References: []ConvertibleReference{ConvertibleReference{Name: "Company", Title: "Common Stock", ConversionRatio: 1.5, Currency: "USD"}}}}}
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5, Lending: &LendingInfo{OnLoan: true, LoanValue: 1000.5}, Lots: []ComponentLot{ComponentLot{Line: 1, Name: "Eli Lilly & Co", Weight: 1}, ComponentLot{Line: 3, Name: "Eli Lilly & Co", Weight: 0.5}}}
+  return []Index{
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{bond, stock}},
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+}
+
+func TestExportCsv(t *testing.T) {
+  tt := []struct {
+    name string
+    from string
+    to string
+    expectedRows int
+  } {
+    {"Whole history", "", "", 3},
+    {"From", "2025-01-02", "", 2},
+    {"To", "", kDate, 1},
+    {"Empty range", "2025-01-02", "2025-01-31", 0},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      buffer := bytes.Buffer{}
+      w, err := newCsvHoldingWriter(&buffer)
+      if err != nil {
+        t.Errorf("Failed to create the writer (err=%+v)", err)
+        return
+      }
+      if err := exportIndexes(w, "VXF", exportTestIndexes(), tc.from, tc.to); err != nil {
+        t.Errorf("Failed to export (err=%+v)", err)
+        return
+      }
+      if err := w.close(); err != nil {
+        t.Errorf("Failed to close the writer (err=%+v)", err)
+        return
+      }
+
+      rows, err := csv.NewReader(&buffer).ReadAll()
+      if err != nil {
+        t.Errorf("Failed to read the CSV (err=%+v)", err)
+        return
+      }
+      if len(rows) != tc.expectedRows + 1 || !reflect.DeepEqual(rows[0], kCsvColumns) {
+        t.Errorf("Expected the header and %d rows but got %+v", tc.expectedRows, rows)
+        return
+      }
+      if tc.expectedRows != 3 {
+        return
+      }
+      expectedBond := []string{"VXF", kValidSeriesId, "2025-02-01", "United States Treasury Strip Coupon", "US912834PZ59", "isin", "2.0219882", "2050-02-15", "none", "0", "false", "false", "false", "true", "false", `[{"name":"Company","title":"Common Stock","conversion_ratio":1.5,"currency":"USD"}]`, "", "", "", "", "", "", ""}
+      if !reflect.DeepEqual(rows[1], expectedBond) {
+        t.Errorf("Mismatched bond row, expected=%+v but got=%+v", expectedBond, rows[1])
+      }
+      expectedStock := []string{"VXF", kValidSeriesId, "2025-02-01", "Eli Lilly & Co", "US5324571083", "isin", "1.5", "", "", "", "", "", "", "", "", "", "true", "1000.5", "false", "0", "false", "0", `[{"line":1,"name":"Eli Lilly & Co","weight":1},{"line":3,"name":"Eli Lilly & Co","weight":0.5}]`}
+      if !reflect.DeepEqual(rows[2], expectedStock) {
+        t.Errorf("Mismatched stock row, expected=%+v but got=%+v", expectedStock, rows[2])
+      }
+    })
+  }
+}
+
+func TestExportNdjson(t *testing.T) {
+  buffer := bytes.Buffer{}
+  w := newNdjsonHoldingWriter(&buffer)
+  indexes := exportTestIndexes()
+  if err := exportIndexes(w, "VXF", indexes, "", ""); err != nil {
+    t.Errorf("Failed to export (err=%+v)", err)
+    return
+  }
+  if err := w.close(); err != nil {
+    t.Errorf("Failed to close the writer (err=%+v)", err)
+    return
+  }
+
+  expected := []ndjsonHolding{
+    ndjsonHolding{"VXF", kValidSeriesId, "2025-02-01", indexes[0].Components[0]},
+    ndjsonHolding{"VXF", kValidSeriesId, "2025-02-01", indexes[0].Components[1]},
+    ndjsonHolding{"VXF", kValidSeriesId, kDate, indexes[1].Components[0]},
+  }
+  scanner := bufio.NewScanner(&buffer)
+  i := 0
+  for ; scanner.Scan(); i++ {
+    holding := ndjsonHolding{}
+    if err := json.Unmarshal(scanner.Bytes(), &holding); err != nil {
+      t.Errorf("Failed to parse line %d: %s (err=%+v)", i, scanner.Text(), err)
+      return
+    }
+    if i >= len(expected) || !reflect.DeepEqual(holding, expected[i]) {
+      t.Errorf("Mismatched line %d: %s", i, scanner.Text())
+      return
+    }
+  }
+  if i != len(expected) {
+    t.Errorf("Expected %d lines but got %d", len(expected), i)
+  }
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "os"
+  "path/filepath"
+)
+
+// Journal of the batch being committed, see fileBatch.
+const kJournalFile = "./data/.pending_writes.json"
+
+// writeTempFile writes `bytes` to a temporary file next to `path`, synced to disk, and returns its path.
+func writeTempFile(path string, bytes []byte) (string, error) {
+  f, err := os.CreateTemp(filepath.Dir(path), "." + filepath.Base(path) + ".tmp-*")
+  if err != nil {
+    return "", err
+  }
+  if _, err := f.Write(bytes); err != nil {
+    f.Close() // ignore error; Write error takes precedence
+    os.Remove(f.Name())
+    return "", err
+  }
+  if err := f.Sync(); err != nil {
+    f.Close() // ignore error; Sync error takes precedence
+    os.Remove(f.Name())
+    return "", err
+  }
+  if err := f.Close(); err != nil {
+    os.Remove(f.Name())
+    return "", err
+  }
+  // CreateTemp uses 0600, we want the same permissions as the other data files.
+  if err := os.Chmod(f.Name(), 0644); err != nil {
+    os.Remove(f.Name())
+    return "", err
+  }
+  return f.Name(), nil
+}
+
+// syncDir syncs the directory `dir` so that the renames inside it are durable.
+func syncDir(dir string) error {
+  f, err := os.Open(dir)
+  if err != nil {
+    return err
+  }
+  if err := f.Sync(); err != nil {
+    f.Close() // ignore error; Sync error takes precedence
+    return err
+  }
+  return f.Close()
+}
+
+// renameSynced renames `temp` to `target` and syncs the target's directory.
+func renameSynced(temp, target string) error {
+  if err := os.Rename(temp, target); err != nil {
+    return err
+  }
+  return syncDir(filepath.Dir(target))
+}
+
+// writeFileAtomically replaces `path` with `bytes`: readers see either the old or the new content.
+func writeFileAtomically(path string, bytes []byte) error {
+  temp, err := writeTempFile(path, bytes)
+  if err != nil {
+    return err
+  }
+  if err := renameSynced(temp, path); err != nil {
+    os.Remove(temp)
+    return err
+  }
+  return nil
+}
+
+type stagedFile struct {
+  // Empty to remove Target.
+  Temp string `json:"temp"`
+  Target string `json:"target"`
+}
+
+// fileBatch writes several files as a group: either all of them are updated or none of them.
+//
+// The files are first written to temporary files. On commit, the list of renames is recorded in
+// a journal before being applied in order, so that a crash in the middle is completed by
+// recoverFileBatch on the next run.
+type fileBatch struct {
+  journalPath string
+  files []stagedFile
+}
+
+func newFileBatch(journalPath string) *fileBatch {
+  return &fileBatch{journalPath, []stagedFile{}}
+}
+
+// add stages `v` to be written to `path`, checking it against the schema `name` if not empty.
+// The files are committed in the order they are added.
+func (b *fileBatch) add(path string, name string, v any) error {
+  if name != "" {
+    if err := validateSchema(name, v); err != nil {
+      return err
+    }
+  }
+  bytes, err := marshalDataJson(v)
+  if err != nil {
+    return err
+  }
+  temp, err := writeTempFile(path, bytes)
+  if err != nil {
+    return err
+  }
+  b.files = append(b.files, stagedFile{temp, path})
+  return nil
+}
+
+// remove stages the removal of `path`, e.g. a file replaced by another one in the batch.
+func (b *fileBatch) remove(path string) {
+  b.files = append(b.files, stagedFile{"", path})
+}
+
+// discard removes the staged files. The batch can't be used afterwards.
+func (b *fileBatch) discard() {
+  for _, file := range b.files {
+    if file.Temp != "" {
+      os.Remove(file.Temp)
+    }
+  }
+  b.files = nil
+}
+
+func (b *fileBatch) commit() error {
+  bytes, err := json.Marshal(b.files)
+  if err != nil {
+    return err
+  }
+  temp, err := writeTempFile(b.journalPath, bytes)
+  if err != nil {
+    return err
+  }
+  // From here on, the batch is committed: recoverFileBatch completes it if we fail.
+  if err := renameSynced(temp, b.journalPath); err != nil {
+    os.Remove(temp)
+    return err
+  }
+  return applyJournal(b.journalPath, b.files)
+}
+
+func applyJournal(journalPath string, files []stagedFile) error {
+  for _, file := range files {
+    if file.Temp == "" {
+      // Already removed before a crash.
+      if err := os.Remove(file.Target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return fmt.Errorf("removing %s: %w", file.Target, err)
+      }
+      if err := syncDir(filepath.Dir(file.Target)); err != nil {
+        return err
+      }
+      continue
+    }
+    err := renameSynced(file.Temp, file.Target)
+    // Already renamed before a crash.
+    if errors.Is(err, fs.ErrNotExist) {
+      continue
+    }
+    if err != nil {
+      return fmt.Errorf("renaming %s to %s: %w", file.Temp, file.Target, err)
+    }
+  }
+  if err := os.Remove(journalPath); err != nil {
+    return err
+  }
+  return syncDir(filepath.Dir(journalPath))
+}
+
+// recoverFileBatch completes the batch committed by an interrupted run, if any.
+func recoverFileBatch(journalPath string) error {
+  files := []stagedFile{}
+  err := readJsonFile(journalPath, &files)
+  if errors.Is(err, fs.ErrNotExist) {
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("reading the journal %s: %w", journalPath, err)
+  }
+  fmt.Printf("Completing the writes of an interrupted run (%d file(s))\n", len(files))
+  return applyJournal(journalPath, files)
+}
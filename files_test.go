@@ -0,0 +1,152 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// listDir returns the names of the files in `dir`, to check that no temporary file is left behind.
+func listDir(t *testing.T, dir string) []string {
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    t.Fatalf("Failed to list %s (err=%+v)", dir, err)
+  }
+  names := []string{}
+  for _, entry := range entries {
+    names = append(names, entry.Name())
+  }
+  return names
+}
+
+func readString(t *testing.T, path string) string {
+  var v string
+  if err := readJsonFile(path, &v); err != nil {
+    t.Fatalf("Failed to read %s (err=%+v)", path, err)
+  }
+  return v
+}
+
+func TestWriteToJsonFile(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "VXF.json")
+  for _, content := range []string{"first", "second"} {
+    if err := writeToJsonFile(path, content); err != nil {
+      t.Errorf("Failed to write %s (err=%+v)", path, err)
+      return
+    }
+    if actual := readString(t, path); actual != content {
+      t.Errorf("Mismatched content, expected=%s but got=%s", content, actual)
+      return
+    }
+  }
+  if names := listDir(t, dir); len(names) != 1 {
+    t.Errorf("Expected only the written file but got %+v", names)
+  }
+}
+
+func TestFileBatch(t *testing.T) {
+  index := Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}
+  tt := []struct {
+    name string
+    // The batch fails if the fetched map is invalid.
+    fetchedMap any
+    committed bool
+  } {
+    {"Commit", FetchedDatesMap{kCompanyId: FilingDateSpan{Start: kDate, End: kDate}}, true},
+    {"Discard when a file doesn't match its schema", map[string]any{"cik": 1}, false},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      dir := t.TempDir()
+      journalPath := filepath.Join(dir, ".pending_writes.json")
+      latestPath := filepath.Join(dir, "VXF.json")
+      fetchedMapPath := filepath.Join(dir, "fetched_map.json")
+      if err := writeToJsonFile(latestPath, "previous"); err != nil {
+        t.Errorf("Failed to write %s (err=%+v)", latestPath, err)
+        return
+      }
+
+      batch := newFileBatch(journalPath)
+      err := batch.add(latestPath, kIndexSchema, index)
+      if err == nil {
+        err = batch.add(fetchedMapPath, kFetchedMapSchema, tc.fetchedMap)
+      }
+      if err == nil {
+        err = batch.commit()
+      } else {
+        batch.discard()
+      }
+      if tc.committed != (err == nil) {
+        t.Errorf("Expected committed=%t but got err=%+v", tc.committed, err)
+        return
+      }
+
+      if tc.committed {
+        latest := Index{}
+        if err := readJsonFile(latestPath, &latest); err != nil || latest.Name != "Index" {
+          t.Errorf("Expected %s to be replaced but got %+v (err=%+v)", latestPath, latest, err)
+        }
+        if names := listDir(t, dir); len(names) != 2 {
+          t.Errorf("Expected only the committed files but got %+v", names)
+        }
+        return
+      }
+      if actual := readString(t, latestPath); actual != "previous" {
+        t.Errorf("Expected %s to be untouched but got %s", latestPath, actual)
+      }
+      if names := listDir(t, dir); len(names) != 1 {
+        t.Errorf("Expected only the previous file but got %+v", names)
+      }
+    })
+  }
+}
+
+func TestRecoverFileBatch(t *testing.T) {
+  dir := t.TempDir()
+  journalPath := filepath.Join(dir, ".pending_writes.json")
+  allPath := filepath.Join(dir, "all.json")
+  fetchedMapPath := filepath.Join(dir, "fetched_map.json")
+  for _, path := range []string{allPath, fetchedMapPath} {
+    if err := writeToJsonFile(path, "previous"); err != nil {
+      t.Errorf("Failed to write %s (err=%+v)", path, err)
+      return
+    }
+  }
+
+  // Simulate a crash after renaming the first file of the batch.
+  batch := newFileBatch(journalPath)
+  for _, path := range []string{allPath, fetchedMapPath} {
+    if err := batch.add(path, "", "new"); err != nil {
+      t.Errorf("Failed to stage %s (err=%+v)", path, err)
+      return
+    }
+  }
+  if err := writeToJsonFile(journalPath, batch.files); err != nil {
+    t.Errorf("Failed to write the journal (err=%+v)", err)
+    return
+  }
+  if err := os.Rename(batch.files[0].Temp, allPath); err != nil {
+    t.Errorf("Failed to rename %s (err=%+v)", batch.files[0].Temp, err)
+    return
+  }
+
+  if err := recoverFileBatch(journalPath); err != nil {
+    t.Errorf("Failed to recover the batch (err=%+v)", err)
+    return
+  }
+  for _, path := range []string{allPath, fetchedMapPath} {
+    if actual := readString(t, path); actual != "new" {
+      t.Errorf("Expected %s to be recovered but got %s", path, actual)
+    }
+  }
+  if names := listDir(t, dir); len(names) != 2 {
+    t.Errorf("Expected only the recovered files but got %+v", names)
+  }
+
+  // Nothing to recover.
+  if err := recoverFileBatch(journalPath); err != nil {
+    t.Errorf("Expected nothing to recover but got err=%+v", err)
+  }
+}
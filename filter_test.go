@@ -2,6 +2,7 @@ package main
 
 import (
   "fmt"
+  "reflect"
   "testing"
 )
 
@@ -22,12 +23,13 @@ func TestFilterFilingDate(t *testing.T) {
     fetchedDates FilingDateSpan
     expected []int
   } {
-    {"No fetchedDates means no filtering", []string{"2025-10-01", "2025-10-01"}, FilingDateSpan{"", ""}, []int{0, 1}},
-    {"Filter a single date at start", []string{"2025-10-01", "2025-10-01", "2025-10-02"}, FilingDateSpan{"2025-10-01", "2025-10-01"}, []int{2}},
-    {"Filter a single date at end", []string{"2025-10-01", "2025-10-01", "2025-10-02"}, FilingDateSpan{"2025-10-02", "2025-10-02"}, []int{0, 1}},
-    {"Filter a single date, removes all", []string{"2025-10-01", "2025-10-01", "2025-10-01"}, FilingDateSpan{"2025-10-01", "2025-10-01"}, []int{}},
-    {"Filter a span at start", []string{"2025-10-01", "2025-10-01", "2025-10-02", "2025-10-03"}, FilingDateSpan{"2025-10-01", "2025-10-02"}, []int{3}},
-    {"Filter a span at end", []string{"2025-10-01", "2025-10-01", "2025-10-02", "2025-10-03"}, FilingDateSpan{"2025-10-02", "2025-10-03"}, []int{0, 1}},
+    {"No fetchedDates means no filtering", []string{"2025-10-01", "2025-10-01"}, FilingDateSpan{Start: "", End: ""}, []int{0, 1}},
+    {"Filter a single date at start", []string{"2025-10-01", "2025-10-01", "2025-10-02"}, FilingDateSpan{Start: "2025-10-01", End: "2025-10-01"}, []int{2}},
+    {"Filter a single date at end", []string{"2025-10-01", "2025-10-01", "2025-10-02"}, FilingDateSpan{Start: "2025-10-02", End: "2025-10-02"}, []int{0, 1}},
+    {"Filter a single date, removes all", []string{"2025-10-01", "2025-10-01", "2025-10-01"}, FilingDateSpan{Start: "2025-10-01", End: "2025-10-01"}, []int{}},
+    {"Filter a span at start", []string{"2025-10-01", "2025-10-01", "2025-10-02", "2025-10-03"}, FilingDateSpan{Start: "2025-10-01", End: "2025-10-02"}, []int{3}},
+    {"Filter a span at end", []string{"2025-10-01", "2025-10-01", "2025-10-02", "2025-10-03"}, FilingDateSpan{Start: "2025-10-02", End: "2025-10-03"}, []int{0, 1}},
+    {"Keep the failed filings", []string{"2025-10-01", "2025-10-01", "2025-10-02"}, FilingDateSpan{Start: "2025-10-01", End: "2025-10-02", Failed: []string{fmt.Sprintf(kAccessionNumberTemplate, 1)}}, []int{1}},
   }
 
   for _, tc := range tt {
@@ -56,18 +58,18 @@ func TestUpdateDateSpan(t *testing.T) {
     newEnd string
     expected FilingDateSpan
   } {
-    {"Same start and end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-01", "2025-01-02", FilingDateSpan{"2025-01-01", "2025-01-02"}},
-    {"Earlier start, same end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-00", "2025-01-02", FilingDateSpan{"2025-01-00", "2025-01-02"}},
-    {"Later start, same end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-02", "2025-01-02", FilingDateSpan{"2025-01-01", "2025-01-02"}},
-    {"Same start, earlier end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-01", "2025-01-01", FilingDateSpan{"2025-01-01", "2025-01-02"}},
-    {"Same start, later end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-01", "2025-01-10", FilingDateSpan{"2025-01-01", "2025-01-10"}},
+    {"Same start and end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-01", "2025-01-02", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}},
+    {"Earlier start, same end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-00", "2025-01-02", FilingDateSpan{Start: "2025-01-00", End: "2025-01-02"}},
+    {"Later start, same end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-02", "2025-01-02", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}},
+    {"Same start, earlier end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-01", "2025-01-01", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}},
+    {"Same start, later end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-01", "2025-01-10", FilingDateSpan{Start: "2025-01-01", End: "2025-01-10"}},
     // Empty FilingDateSpan
-    {"Empty, setting start/end", FilingDateSpan{"", ""}, "2025-01-01", "2025-01-02", FilingDateSpan{"2025-01-01", "2025-01-02"}},
+    {"Empty, setting start/end", FilingDateSpan{Start: "", End: ""}, "2025-01-01", "2025-01-02", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}},
     // Case where start/end were swapped.
-    {"Same start, end earlier than start", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-01", "2025-01-00", FilingDateSpan{"2025-01-00", "2025-01-02"}},
-    {"start after end, same end", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-03", "2025-01-02", FilingDateSpan{"2025-01-01", "2025-01-03"}},
-    {"start/end swapped", FilingDateSpan{"2025-01-01", "2025-01-02"}, "2025-01-03", "2025-01-00", FilingDateSpan{"2025-01-00", "2025-01-03"}},
-    {"empty, start/end swapped", FilingDateSpan{"", ""}, "2025-01-02", "2025-01-01", FilingDateSpan{"2025-01-01", "2025-01-02"}},
+    {"Same start, end earlier than start", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-01", "2025-01-00", FilingDateSpan{Start: "2025-01-00", End: "2025-01-02"}},
+    {"start after end, same end", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-03", "2025-01-02", FilingDateSpan{Start: "2025-01-01", End: "2025-01-03"}},
+    {"start/end swapped", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}, "2025-01-03", "2025-01-00", FilingDateSpan{Start: "2025-01-00", End: "2025-01-03"}},
+    {"empty, start/end swapped", FilingDateSpan{Start: "", End: ""}, "2025-01-02", "2025-01-01", FilingDateSpan{Start: "2025-01-01", End: "2025-01-02"}},
   }
 
   for _, tc := range tt {
@@ -84,3 +86,29 @@ func TestUpdateDateSpan(t *testing.T) {
     })
   }
 }
+
+func TestUpdateFailed(t *testing.T) {
+  infos := generateSubmissionInfos([]string{"2025-10-03", "2025-10-02"})
+  tt := []struct {
+    name string
+    previous []string
+    failed []string
+    expected []string
+  } {
+    {"No failure", nil, []string{}, nil},
+    {"New failure", nil, []string{infos[0].AccessionNumber}, []string{infos[0].AccessionNumber}},
+    {"Fetched again", []string{infos[1].AccessionNumber}, []string{}, nil},
+    {"Failed again", []string{infos[1].AccessionNumber}, []string{infos[1].AccessionNumber}, []string{infos[1].AccessionNumber}},
+    {"Not fetched", []string{"0000350001-04-00001"}, []string{infos[0].AccessionNumber}, []string{"0000350001-04-00001", infos[0].AccessionNumber}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      span := FilingDateSpan{Start: "2025-10-01", End: "2025-10-01", Failed: tc.previous}
+      span.updateFailed(infos, tc.failed)
+      if !reflect.DeepEqual(span.Failed, tc.expected) {
+        t.Errorf("Mismatched failed filings, expected=%v but got=%v", tc.expected, span.Failed)
+      }
+    })
+  }
+}
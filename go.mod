@@ -4,6 +4,28 @@ go 1.24.2
 
 replace edgar_client => ./edgar_client
 
-require edgar_client v0.0.0-00010101000000-000000000000
+replace github.com/jchaffraix/vanguard_etfs/etf_data => ./etf_data
 
-require github.com/jmhodges/clock v1.2.0 // indirect
+require (
+	edgar_client v0.0.0-00010101000000-000000000000
+	github.com/jchaffraix/vanguard_etfs/etf_data v0.0.0-00010101000000-000000000000
+	github.com/parquet-go/parquet-go v0.25.1
+	modernc.org/sqlite v1.40.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/jmhodges/clock v1.2.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	modernc.org/libc v1.66.10 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
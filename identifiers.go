@@ -0,0 +1,116 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "regexp"
+)
+
+var kIsinRegexp = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+// CINS are CUSIP whose first character is a letter (identifying the country).
+var kCusipRegexp = regexp.MustCompile(`^[A-Z0-9*@#]{8}[0-9]$`)
+// SEDOL don't use vowels.
+var kSedolRegexp = regexp.MustCompile(`^[0-9BCDFGHJKLMNPQRSTVWXYZ]{6}[0-9]$`)
+// Tickers are free-form, but we don't expect spaces or lowercase letters.
+var kTickerRegexp = regexp.MustCompile(`^[A-Z0-9][A-Z0-9./-]{0,11}$`)
+
+// charValue converts a digit or an uppercase letter to its value: '0'-'9' are 0-9 and 'A'-'Z' are 10-35.
+func charValue(c byte) int {
+  if c >= '0' && c <= '9' {
+    return int(c - '0')
+  }
+  return int(c - 'A') + 10
+}
+
+func checkDigit(id string, expected int) error {
+  actual := int(id[len(id) - 1] - '0')
+  if actual != expected {
+    return fmt.Errorf("invalid check digit %d, expected %d", actual, expected)
+  }
+  return nil
+}
+
+// ISIN check digit: Luhn algorithm over the string where letters are converted to their value.
+func isinCheckDigit(id string) int {
+  digits := []int{}
+  for i := 0; i < len(id) - 1; i++ {
+    v := charValue(id[i])
+    if v >= 10 {
+      digits = append(digits, v / 10)
+    }
+    digits = append(digits, v % 10)
+  }
+  sum := 0
+  // Double every other digit, starting from the rightmost one.
+  for i := range digits {
+    d := digits[len(digits) - 1 - i]
+    if i % 2 == 0 {
+      d *= 2
+      if d > 9 {
+        d -= 9
+      }
+    }
+    sum += d
+  }
+  return (10 - sum % 10) % 10
+}
+
+// CUSIP/CINS check digit: modulus 10 "double-add-double".
+func cusipCheckDigit(id string) int {
+  sum := 0
+  for i := 0; i < len(id) - 1; i++ {
+    var v int
+    switch id[i] {
+      case '*':
+        v = 36
+      case '@':
+        v = 37
+      case '#':
+        v = 38
+      default:
+        v = charValue(id[i])
+    }
+    if i % 2 == 1 {
+      v *= 2
+    }
+    sum += v / 10 + v % 10
+  }
+  return (10 - sum % 10) % 10
+}
+
+// SEDOL check digit: weighted sum modulus 10.
+func sedolCheckDigit(id string) int {
+  weights := []int{1, 3, 1, 7, 3, 9}
+  sum := 0
+  for i, weight := range weights {
+    sum += charValue(id[i]) * weight
+  }
+  return (10 - sum % 10) % 10
+}
+
+// validateIdentifier returns an error explaining why `id` is malformed for `idType`.
+// Types without a known format (e.g. "faid", "vid") are not validated.
+func validateIdentifier(id, idType string) error {
+  switch idType {
+    case "isin":
+      if !kIsinRegexp.MatchString(id) {
+        return errors.New("not 2 letters followed by 9 alphanumerics and a digit")
+      }
+      return checkDigit(id, isinCheckDigit(id))
+    case "cusip", "cins":
+      if !kCusipRegexp.MatchString(id) {
+        return errors.New("not 8 alphanumerics followed by a digit")
+      }
+      return checkDigit(id, cusipCheckDigit(id))
+    case "sedol":
+      if !kSedolRegexp.MatchString(id) {
+        return errors.New("not 6 alphanumerics without vowels followed by a digit")
+      }
+      return checkDigit(id, sedolCheckDigit(id))
+    case "ticker":
+      if !kTickerRegexp.MatchString(id) {
+        return errors.New("not a ticker (up to 12 uppercase alphanumerics, '.', '/' or '-')")
+      }
+  }
+  return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// marshalDataJson encodes `v` for the data files, so that `git diff` on them is meaningful:
+//  - The objects and arrays are indented by 2 spaces, except that the objects in arrays (e.g. the
+//    components, lots or months) are written on a single line. The filings (objects with `components`)
+//    are still indented.
+//  - The numbers are in fixed-point notation (e.g. 0.000000000987 instead of 9.87e-10), with the
+//    shortest representation of the value.
+//  - The struct fields keep their order and the map keys are sorted, as with json.Marshal.
+//  - "&", "<" and ">" aren't escaped.
+// The result ends with a newline.
+func marshalDataJson(v any) ([]byte, error) {
+  buffer := bytes.Buffer{}
+  encoder := json.NewEncoder(&buffer)
+  encoder.SetEscapeHTML(false)
+  if err := encoder.Encode(v); err != nil {
+    return nil, err
+  }
+
+  // The value is decoded once, the layout of each container only depends on its members.
+  decoder := json.NewDecoder(&buffer)
+  decoder.UseNumber()
+  value, err := decodeJsonValue(decoder)
+  if err != nil {
+    return nil, err
+  }
+  res := bytes.Buffer{}
+  if err := formatDataJson(&res, value, "", false); err != nil {
+    return nil, err
+  }
+  res.WriteByte('\n')
+  return res.Bytes(), nil
+}
+
+// jsonValue is a decoded JSON value that keeps the order of the object members.
+type jsonValue struct {
+  // '{' for the objects, '[' for the arrays, '"' for the strings and 0 for the other scalars.
+  kind byte
+  // The string, or the encoded number, boolean or null.
+  scalar string
+  members []jsonMember
+}
+
+type jsonMember struct {
+  // Unset for the array elements.
+  key string
+  value jsonValue
+}
+
+// decodeJsonValue reads the next value of `decoder`, which must use numbers.
+func decodeJsonValue(decoder *json.Decoder) (jsonValue, error) {
+  token, err := decoder.Token()
+  if err != nil {
+    return jsonValue{}, err
+  }
+  switch t := token.(type) {
+    case json.Delim:
+      value := jsonValue{kind: byte(t)}
+      for decoder.More() {
+        member := jsonMember{}
+        if value.kind == '{' {
+          key, err := decoder.Token()
+          if err != nil {
+            return jsonValue{}, err
+          }
+          member.key = key.(string)
+        }
+        if member.value, err = decodeJsonValue(decoder); err != nil {
+          return jsonValue{}, err
+        }
+        value.members = append(value.members, member)
+      }
+      // The closing delimiter.
+      if _, err := decoder.Token(); err != nil {
+        return jsonValue{}, err
+      }
+      return value, nil
+    case string:
+      return jsonValue{kind: '"', scalar: t}, nil
+    case json.Number:
+      number, err := formatJsonNumber(t.String())
+      if err != nil {
+        return jsonValue{}, fmt.Errorf("invalid number %s: %w", t, err)
+      }
+      return jsonValue{scalar: number}, nil
+    case bool:
+      return jsonValue{scalar: strconv.FormatBool(t)}, nil
+    default:
+      return jsonValue{scalar: "null"}, nil
+  }
+}
+
+// formatJsonNumber rewrites the number `s` in fixed-point notation.
+func formatJsonNumber(s string) (string, error) {
+  if !strings.ContainsAny(s, "eE") {
+    return s, nil
+  }
+  f, err := strconv.ParseFloat(s, 64)
+  if err != nil {
+    return "", err
+  }
+  return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+// formatDataJson writes `value` to `w`, see marshalDataJson. `indent` is the indentation of the line where
+// the value starts.
+func formatDataJson(w *bytes.Buffer, value jsonValue, indent string, inline bool) error {
+  switch value.kind {
+    case '{', '[':
+      isObject := value.kind == '{'
+      closing := byte(']')
+      if isObject {
+        closing = '}'
+      }
+      w.WriteByte(value.kind)
+      if len(value.members) == 0 {
+        w.WriteByte(closing)
+        return nil
+      }
+      for i, member := range value.members {
+        if i > 0 {
+          w.WriteByte(',')
+        }
+        if !inline {
+          w.WriteString("\n" + indent + "  ")
+        }
+        if isObject {
+          if err := writeJsonString(w, member.key); err != nil {
+            return err
+          }
+          w.WriteByte(':')
+          if !inline {
+            w.WriteByte(' ')
+          }
+        }
+        elementInline := inline || (!isObject && member.value.kind == '{' && !member.value.hasKey("components"))
+        if err := formatDataJson(w, member.value, indent + "  ", elementInline); err != nil {
+          return err
+        }
+      }
+      if !inline {
+        w.WriteString("\n" + indent)
+      }
+      w.WriteByte(closing)
+    case '"':
+      return writeJsonString(w, value.scalar)
+    default:
+      w.WriteString(value.scalar)
+  }
+  return nil
+}
+
+// hasKey returns whether the object `v` has the member `key`.
+func (v jsonValue) hasKey(key string) bool {
+  for _, member := range v.members {
+    if member.key == key {
+      return true
+    }
+  }
+  return false
+}
+
+func writeJsonString(w *bytes.Buffer, s string) error {
+  encoder := json.NewEncoder(w)
+  encoder.SetEscapeHTML(false)
+  if err := encoder.Encode(s); err != nil {
+    return err
+  }
+  // Encode terminates the value with a newline.
+  w.Truncate(w.Len() - 1)
+  return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+  "testing"
+)
+
+func TestMarshalDataJson(t *testing.T) {
+  component := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 0.000000000987, Lots: []ComponentLot{ComponentLot{Line: 1, Name: "Eli Lilly & Co", Weight: 0.000000000987}}}
+  tt := []struct {
+    name string
+    v any
+    expected string
+  } {
+    {"Scalar", "a", "\"a\"\n"},
+    {"Empty containers", map[string]any{"a": []int{}, "b": map[string]int{}}, "{\n  \"a\": [],\n  \"b\": {}\n}\n"},
+    {"Fixed-point numbers", []float32{0.000000000987, 1e-7, 12.5, 1e20}, "[\n  0.000000000987,\n  0.0000001,\n  12.5,\n  100000000000000000000\n]\n"},
+    {"Sorted map keys", FetchedDatesMap{52848: FilingDateSpan{Start: "", End: ""}, 36405: FilingDateSpan{Start: "2019-11-27", End: kDate}}, `{
+  "36405": {
+    "start": "2019-11-27",
+    "end": "2025-01-01"
+  },
+  "52848": {
+    "start": "",
+    "end": ""
+  }
+}
+`},
+    {"One component per line", []Index{Index{SchemaVersion: 1, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{component, component}, Weights: &WeightTotals{Components: 100, Derivatives: 0, Cash: 0, Residual: 0}}}, `[
+  {
+    "schema_version": 1,
+    "name": "Index",
+    "series_id": "S000002841",
+    "filing_date": "2025-01-01",
+    "components": [
+      {"name":"Eli Lilly & Co","id":"US5324571083","id_type":"isin","weight":0.000000000987,"lots":[{"line":1,"name":"Eli Lilly & Co","weight":0.000000000987}]},
+      {"name":"Eli Lilly & Co","id":"US5324571083","id_type":"isin","weight":0.000000000987,"lots":[{"line":1,"name":"Eli Lilly & Co","weight":0.000000000987}]}
+    ],
+    "weights": {
+      "components": 100,
+      "derivatives": 0,
+      "cash": 0,
+      "residual": 0
+    }
+  }
+]
+`},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      bytes, err := marshalDataJson(tc.v)
+      if err != nil {
+        t.Errorf("Failed to marshal (err=%+v)", err)
+        return
+      }
+      if string(bytes) != tc.expected {
+        t.Errorf("Mismatched JSON, expected=%s but got=%s", tc.expected, bytes)
+      }
+    })
+  }
+}
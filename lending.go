@@ -0,0 +1,107 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+)
+
+// Values of FundLending.PctOnLoanBasis.
+const kPctOnLoanFromNetAssets = "net_assets"
+const kPctOnLoanFromWeights = "weights"
+
+// Subset of the `<securityLending>` block of the N-PORT specification.
+// Each flag is reported either as a plain Y/N element or, when it is set,
+// as a "condition" element carrying the value (in USD).
+type securityLending struct {
+  IsCashCollateral string `xml:"isCashCollateral"`
+  CashCollateralCondition struct {
+    IsCashCollateral string `xml:"isCashCollateral,attr"`
+    CashCollateralVal string `xml:"cashCollateralVal,attr"`
+  } `xml:"cashCollateralCondition"`
+  IsNonCashCollateral string `xml:"isNonCashCollateral"`
+  NonCashCollateralCondition struct {
+    IsNonCashCollateral string `xml:"isNonCashCollateral,attr"`
+    NonCashCollateralVal string `xml:"nonCashCollateralVal,attr"`
+  } `xml:"nonCashCollateralCondition"`
+  IsLoanByFund string `xml:"isLoanByFund"`
+  LoanByFundCondition struct {
+    IsLoanByFund string `xml:"isLoanByFund,attr"`
+    LoanVal string `xml:"loanVal,attr"`
+  } `xml:"loanByFundCondition"`
+}
+
+// Fund-level list of the securities lending borrowers, from `<fundInfo>`.
+type borrowers struct {
+  Borrower []struct {
+    Name string `xml:"name,attr"`
+    Lei string `xml:"lei,attr"`
+    AggrVal string `xml:"aggrVal,attr"`
+  } `xml:"borrower"`
+}
+
+// getLendingInfo returns nil if the component doesn't have any lending activity.
+func getLendingInfo(l securityLending) *LendingInfo {
+  info := LendingInfo{
+    OnLoan: parseYesNo(l.IsLoanByFund) || parseYesNo(l.LoanByFundCondition.IsLoanByFund),
+    LoanValue: parseFloat64(l.LoanByFundCondition.LoanVal),
+    CashCollateral: parseYesNo(l.IsCashCollateral) || parseYesNo(l.CashCollateralCondition.IsCashCollateral),
+    CashCollateralValue: parseFloat64(l.CashCollateralCondition.CashCollateralVal),
+    NonCashCollateral: parseYesNo(l.IsNonCashCollateral) || parseYesNo(l.NonCashCollateralCondition.IsNonCashCollateral),
+    NonCashCollateralValue: parseFloat64(l.NonCashCollateralCondition.NonCashCollateralVal),
+  }
+  if !info.OnLoan && !info.CashCollateral && !info.NonCashCollateral {
+    return nil
+  }
+  return &info
+}
+
+// computeFundLending returns nil if the fund doesn't lend any of its components.
+func computeFundLending(index Index, netAssets float64, b borrowers) *FundLending {
+  lending := FundLending{PctOnLoan: 0, PctOnLoanBasis: kPctOnLoanFromNetAssets, ComponentsOnLoan: 0, Borrowers: []Borrower{}}
+  var loanValue, loanWeight float64
+  for _, component := range index.Components {
+    if component.Lending == nil || !component.Lending.OnLoan {
+      continue
+    }
+    lending.ComponentsOnLoan++
+    loanValue += component.Lending.LoanValue
+    loanWeight += float64(component.Weight)
+  }
+  for _, borrower := range b.Borrower {
+    lending.Borrowers = append(lending.Borrowers, Borrower{Name: borrower.Name, Lei: borrower.Lei, Value: parseFloat64(borrower.AggrVal)})
+  }
+  if lending.ComponentsOnLoan == 0 && len(lending.Borrowers) == 0 {
+    return nil
+  }
+
+  if netAssets > 0 && loanValue > 0 {
+    lending.PctOnLoan = float32(loanValue / netAssets * 100)
+  } else {
+    lending.PctOnLoan = float32(loanWeight)
+    lending.PctOnLoanBasis = kPctOnLoanFromWeights
+  }
+  return &lending
+}
+
+func runLendingReport(args []string) error {
+  flags := flag.NewFlagSet("lending", flag.ExitOnError)
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to report on. Defaults to all ETFs")
+  flags.Parse(args)
+
+  fmt.Printf("%-6s %-11s %12s %-10s %18s %10s\n", "ETF", "Filing date", "% on loan", "Basis", "Components on loan", "Borrowers")
+  for _, etf := range selectEtfs(*etfsFlag) {
+    indexes, err := readAllIndexes(etf)
+    if err != nil {
+      fmt.Printf("Skipping %s as its file couldn't be read (err=%+v)\n", etf, err)
+      continue
+    }
+    for _, index := range indexes {
+      if index.Lending == nil {
+        fmt.Printf("%-6s %-11s %12s %-10s %18s %10s\n", etf, index.FilingDate, "N/A", "N/A", "N/A", "N/A")
+        continue
+      }
+      fmt.Printf("%-6s %-11s %12.4f %-10s %18d %10d\n", etf, index.FilingDate, index.Lending.PctOnLoan, index.Lending.PctOnLoanBasis, index.Lending.ComponentsOnLoan, len(index.Lending.Borrowers))
+    }
+  }
+  return nil
+}
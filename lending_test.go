@@ -0,0 +1,85 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "testing"
+)
+
+func TestLendingInfo(t *testing.T) {
+  tt := []struct {
+    name string
+    invstOrSecXml string
+    expected *LendingInfo
+  } {
+    {"No lending", `<invstOrSec><name>Warby Parker Inc</name><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.0035</pctVal><securityLending><isCashCollateral>N</isCashCollateral><isNonCashCollateral>N</isNonCashCollateral><isLoanByFund>N</isLoanByFund></securityLending></invstOrSec>`, nil},
+    {"No securityLending block", `<invstOrSec><name>Warby Parker Inc</name><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.0035</pctVal></invstOrSec>`, nil},
+    {"On loan with value", `<invstOrSec><name>Warby Parker Inc</name><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.0035</pctVal><securityLending><isCashCollateral>N</isCashCollateral><isNonCashCollateral>N</isNonCashCollateral><loanByFundCondition isLoanByFund="Y" loanVal="1234.5"/></securityLending></invstOrSec>`, &LendingInfo{OnLoan: true, LoanValue: 1234.5, CashCollateral: false, CashCollateralValue: 0, NonCashCollateral: false, NonCashCollateralValue: 0}},
+    {"Cash collateral", `<invstOrSec><name>Vanguard Market Liquidity Fund</name><identifiers><other otherDesc="FAID" value="CMT001142"/></identifiers><pctVal>0.0094</pctVal><securityLending><cashCollateralCondition isCashCollateral="Y" cashCollateralVal="5000"/><isNonCashCollateral>N</isNonCashCollateral><isLoanByFund>N</isLoanByFund></securityLending></invstOrSec>`, &LendingInfo{OnLoan: false, LoanValue: 0, CashCollateral: true, CashCollateralValue: 5000, NonCashCollateral: false, NonCashCollateralValue: 0}},
+    {"Non-cash collateral", `<invstOrSec><name>Bond</name><identifiers><isin value="US912834PZ59"/></identifiers><pctVal>0.0094</pctVal><securityLending><isCashCollateral>N</isCashCollateral><nonCashCollateralCondition isNonCashCollateral="Y" nonCashCollateralVal="N/A"/><isLoanByFund>N</isLoanByFund></securityLending></invstOrSec>`, &LendingInfo{OnLoan: false, LoanValue: 0, CashCollateral: false, CashCollateralValue: 0, NonCashCollateral: true, NonCashCollateralValue: 0}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      info := getLendingInfo(parseSingleInvstOrSec(tc.invstOrSecXml).SecurityLending)
+      if tc.expected == nil {
+        if info != nil {
+          t.Errorf("Expected no lending information but got %+v", info)
+        }
+        return
+      }
+      if info == nil {
+        t.Errorf("Expected lending information %+v but got none", tc.expected)
+        return
+      }
+      if *info != *tc.expected {
+        t.Errorf("Mismatched lending information, expected=%+v but got=%+v", tc.expected, info)
+        return
+      }
+    })
+  }
+}
+
+func onLoan(weight float32, loanValue float64) IndexComponent {
+  return IndexComponent{Name: "Company", Id: "US93403J1060", IdType: "isin", Weight: weight, Lending: &LendingInfo{OnLoan: true, LoanValue: loanValue}}
+}
+
+func TestFundLending(t *testing.T) {
+  tt := []struct {
+    name string
+    components []IndexComponent
+    netAssets float64
+    borrowersXml string
+    // nil if no lending is expected.
+    expected *FundLending
+  } {
+    {"No lending", []IndexComponent{IndexComponent{Name: "Company", Id: "US93403J1060", IdType: "isin", Weight: 1}}, 1000, ``, nil},
+    {"Percentage from the loan values", []IndexComponent{onLoan(1, 10), onLoan(2, 40)}, 1000, `<borrower name="Bank" lei="LEI1" aggrVal="50"/>`, &FundLending{PctOnLoan: 5, PctOnLoanBasis: kPctOnLoanFromNetAssets, ComponentsOnLoan: 2, Borrowers: []Borrower{Borrower{Name: "Bank", Lei: "LEI1", Value: 50}}}},
+    {"Percentage from the weights without net assets", []IndexComponent{onLoan(1, 10), onLoan(2, 40)}, 0, ``, &FundLending{PctOnLoan: 3, PctOnLoanBasis: kPctOnLoanFromWeights, ComponentsOnLoan: 2, Borrowers: []Borrower{}}},
+    {"Percentage from the weights without loan values", []IndexComponent{onLoan(1.5, 0)}, 1000, ``, &FundLending{PctOnLoan: 1.5, PctOnLoanBasis: kPctOnLoanFromWeights, ComponentsOnLoan: 1, Borrowers: []Borrower{}}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      b := borrowers{}
+      if err := xml.Unmarshal([]byte(fmt.Sprintf("<borrowers>%s</borrowers>", tc.borrowersXml)), &b); err != nil {
+        panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", tc.borrowersXml, err))
+      }
+      lending := computeFundLending(Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: tc.components}, tc.netAssets, b)
+      if tc.expected == nil {
+        if lending != nil {
+          t.Errorf("Expected no lending but got %+v", lending)
+        }
+        return
+      }
+      if lending == nil {
+        t.Errorf("Expected lending %+v but got none", tc.expected)
+        return
+      }
+      if fmt.Sprintf("%+v", *lending) != fmt.Sprintf("%+v", *tc.expected) {
+        t.Errorf("Mismatched lending, expected=%+v but got=%+v", tc.expected, lending)
+        return
+      }
+    })
+  }
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "os"
+  "syscall"
+  "time"
+)
+
+// Advisory lock held by the run writing to the data directory.
+const kLockFile = "./data/.lock"
+// A lock older than this is considered stale even if we can't check its process (e.g. on another host).
+const kStaleLockAge = 12 * time.Hour
+
+// Content of the lock file, identifying the run holding it.
+type lockInfo struct {
+  Pid int `json:"pid"`
+  Host string `json:"host"`
+  // Format: RFC3339.
+  Timestamp string `json:"timestamp"`
+}
+
+type dataLock struct {
+  path string
+}
+
+func processAlive(pid int) bool {
+  p, err := os.FindProcess(pid)
+  if err != nil {
+    return false
+  }
+  // Signal 0 only checks that the process exists. EPERM means it exists but belongs to another user.
+  err = p.Signal(syscall.Signal(0))
+  return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// isStale returns why the lock `info` is stale, or "" if it's held.
+func (info lockInfo) isStale(host string, now time.Time) string {
+  timestamp, err := time.Parse(time.RFC3339, info.Timestamp)
+  if err != nil {
+    return fmt.Sprintf("invalid timestamp %q", info.Timestamp)
+  }
+  if age := now.Sub(timestamp); age > kStaleLockAge {
+    return fmt.Sprintf("acquired %s ago", age.Round(time.Minute))
+  }
+  if info.Host == host && !processAlive(info.Pid) {
+    return fmt.Sprintf("process %d isn't running anymore", info.Pid)
+  }
+  return ""
+}
+
+// readLockInfo reads the lock at `path` and returns its content along with the parsed information.
+func readLockInfo(path string) (lockInfo, []byte, error) {
+  info := lockInfo{}
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return info, nil, err
+  }
+  err = json.Unmarshal(content, &info)
+  return info, content, err
+}
+
+// removeStaleLock removes the lock at `path` if its content is still `stale`.
+// Another run may have replaced it since it was read, so the lock is first moved aside, which only one run can
+// do, then compared. A lock replaced in the meantime is put back: Link fails instead of replacing a lock that
+// was created since.
+func removeStaleLock(path string, stale []byte) error {
+  host, err := os.Hostname()
+  if err != nil {
+    return err
+  }
+  moved := fmt.Sprintf("%s.stale-%s-%d", path, host, os.Getpid())
+  if err := os.Rename(path, moved); err != nil {
+    if errors.Is(err, fs.ErrNotExist) {
+      // Removed by another run.
+      return nil
+    }
+    return err
+  }
+  content, err := os.ReadFile(moved)
+  if err != nil {
+    return err
+  }
+  if string(content) != string(stale) {
+    if err := os.Link(moved, path); err != nil {
+      return fmt.Errorf("putting back the lock %s of another run, moved to %s: %w", path, moved, err)
+    }
+  }
+  return os.Remove(moved)
+}
+
+// acquireDataLock acquires the lock at `path`, replacing it if it's stale.
+// It fails if another run holds it.
+func acquireDataLock(path string) (*dataLock, error) {
+  host, err := os.Hostname()
+  if err != nil {
+    return nil, err
+  }
+  bytes, err := json.Marshal(lockInfo{os.Getpid(), host, time.Now().UTC().Format(time.RFC3339)})
+  if err != nil {
+    return nil, err
+  }
+
+  // We only retry once after removing a stale lock, in case another run races us.
+  // If it replaced the stale lock first, the retry reports it as held.
+  for attempt := 0; attempt < 2; attempt++ {
+    f, err := os.OpenFile(path, os.O_CREATE | os.O_EXCL | os.O_WRONLY, 0644)
+    if err == nil {
+      if _, err := f.Write(bytes); err != nil {
+        f.Close() // ignore error; Write error takes precedence
+        os.Remove(path) // ignore error; Write error takes precedence
+        return nil, err
+      }
+      if err := f.Close(); err != nil {
+        os.Remove(path) // ignore error; Close error takes precedence
+        return nil, err
+      }
+      return &dataLock{path}, nil
+    }
+    if !errors.Is(err, fs.ErrExist) {
+      return nil, err
+    }
+
+    info, content, err := readLockInfo(path)
+    if errors.Is(err, fs.ErrNotExist) {
+      // Released in the meantime.
+      continue
+    }
+    if err != nil {
+      // The holder may not have written its information yet.
+      return nil, fmt.Errorf("the data directory is locked by another run (unreadable %s: %w)", path, err)
+    }
+    reason := info.isStale(host, time.Now())
+    if reason == "" {
+      return nil, fmt.Errorf("the data directory is locked by another run (pid=%d, host=%s, since %s). If that's not the case, remove %s", info.Pid, info.Host, info.Timestamp, path)
+    }
+    fmt.Printf("Removing the stale lock %s of pid=%d, host=%s: %s\n", path, info.Pid, info.Host, reason)
+    if err := removeStaleLock(path, content); err != nil {
+      return nil, err
+    }
+  }
+  return nil, fmt.Errorf("the data directory is locked by another run (%s keeps being recreated)", path)
+}
+
+func (l *dataLock) release() error {
+  return os.Remove(l.path)
+}
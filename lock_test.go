@@ -0,0 +1,130 @@
+package main
+
+import (
+  "errors"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestAcquireDataLock(t *testing.T) {
+  host, err := os.Hostname()
+  if err != nil {
+    t.Errorf("Failed to get the hostname (err=%+v)", err)
+    return
+  }
+  now := time.Now().UTC()
+  tt := []struct {
+    name string
+    // nil if there's no existing lock.
+    existing *lockInfo
+    acquired bool
+  } {
+    {"No lock", nil, true},
+    {"Held by a running process", &lockInfo{os.Getpid(), host, now.Format(time.RFC3339)}, false},
+    {"Held on another host", &lockInfo{1, "other-host", now.Add(-time.Hour).Format(time.RFC3339)}, false},
+    // PIDs are at most 2^22 on Linux.
+    {"Stale, the process isn't running", &lockInfo{1 << 30, host, now.Format(time.RFC3339)}, true},
+    {"Stale, too old", &lockInfo{1, "other-host", now.Add(-2 * kStaleLockAge).Format(time.RFC3339)}, true},
+    {"Stale, invalid timestamp", &lockInfo{1, "other-host", "yesterday"}, true},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      path := filepath.Join(t.TempDir(), ".lock")
+      if tc.existing != nil {
+        if err := writeToJsonFile(path, *tc.existing); err != nil {
+          t.Errorf("Failed to write the existing lock (err=%+v)", err)
+          return
+        }
+      }
+
+      lock, err := acquireDataLock(path)
+      if !tc.acquired {
+        if err == nil || !strings.Contains(err.Error(), "locked by another run") {
+          t.Errorf("Expected the lock to be held by another run but got err=%+v", err)
+        }
+        return
+      }
+      if err != nil {
+        t.Errorf("Failed to acquire the lock (err=%+v)", err)
+        return
+      }
+      info := lockInfo{}
+      if err := readJsonFile(path, &info); err != nil || info.Pid != os.Getpid() || info.Host != host {
+        t.Errorf("Unexpected lock content %+v (err=%+v)", info, err)
+        return
+      }
+
+      // A second run can't acquire the lock until it's released.
+      if _, err := acquireDataLock(path); err == nil {
+        t.Errorf("Expected the lock to be held")
+        return
+      }
+      if err := lock.release(); err != nil {
+        t.Errorf("Failed to release the lock (err=%+v)", err)
+        return
+      }
+      lock, err = acquireDataLock(path)
+      if err != nil {
+        t.Errorf("Failed to acquire the released lock (err=%+v)", err)
+        return
+      }
+      lock.release()
+    })
+  }
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+  stale := []byte(`{"pid":1,"host":"other-host","timestamp":"yesterday"}`)
+  fresh := []byte(`{"pid":2,"host":"other-host","timestamp":"today"}`)
+  tt := []struct {
+    name string
+    // nil if there's no lock anymore.
+    existing []byte
+    // Content of the lock after the removal, nil if removed.
+    expected []byte
+  } {
+    {"Still stale", stale, nil},
+    {"Replaced by another run", fresh, fresh},
+    {"Removed by another run", nil, nil},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      dir := t.TempDir()
+      path := filepath.Join(dir, ".lock")
+      if tc.existing != nil {
+        if err := os.WriteFile(path, tc.existing, 0644); err != nil {
+          t.Errorf("Failed to write the existing lock (err=%+v)", err)
+          return
+        }
+      }
+      if err := removeStaleLock(path, stale); err != nil {
+        t.Errorf("Failed to remove the stale lock (err=%+v)", err)
+        return
+      }
+      content, err := os.ReadFile(path)
+      if tc.expected == nil {
+        if !errors.Is(err, fs.ErrNotExist) {
+          t.Errorf("Expected the lock to be removed but got %s (err=%+v)", content, err)
+        }
+      } else if err != nil || string(content) != string(tc.expected) {
+        t.Errorf("Expected the lock %s but got %s (err=%+v)", tc.expected, content, err)
+        return
+      }
+      // The moved lock is removed.
+      expectedFiles := []string{}
+      if tc.expected != nil {
+        expectedFiles = []string{".lock"}
+      }
+      if files := listDir(t, dir); !reflect.DeepEqual(files, expectedFiles) {
+        t.Errorf("Expected the files %v but got %v", expectedFiles, files)
+      }
+    })
+  }
+}
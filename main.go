@@ -1,12 +1,20 @@
 package main
 
 import (
+  "crypto/sha256"
+  "encoding/hex"
   "encoding/json"
   "encoding/xml"
+  "errors"
+  "flag"
   "fmt"
+  "io/fs"
+  "maps"
   "os"
   "edgar_client"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
   "slices"
+  "strconv"
   "strings"
 )
 
@@ -18,17 +26,20 @@ const kUrlSingleSubmissionXml = "https://www.sec.gov/Archives/edgar/data/%d/%s/p
 // to 10 digits (per EDGAR's format).
 const kUrlAllSubmissionsJson = "https://data.sec.gov/submissions/CIK%010d.json"
 
-const kFetchedMapFile = "./data/fetched_map.json"
-const kStoredIndexFile = "./all_etfs.json"
+const kFetchedMapFile = "./data/" + kFetchedMapFileName
 
 // Subset of:
 // https://www.sec.gov/info/edgar/specifications/form-n-port-xml-tech-specs.htm
 type invstOrSec struct {
   Name string `xml:"name"`
+  Lei string `xml:"lei"`
+  Title string `xml:"title"`
   // The percentages are reported up to E-12 so we shouldn't experience
   // a loss of precision using float32 based on this underflow table:
   // https://docs.oracle.com/cd/E60778_01/html/E60763/z4000ac020351.html
   PctVal float32 `xml:"pctVal"`
+  // e.g. "EC" for common equity, "DBT" for debt or "STIV" for short-term investment vehicles.
+  AssetCat string `xml:"assetCat"`
   // We don't use `xml:"cusip"` as it is N/A for international stock and `<isin>` contains it.
   Identifiers struct {
     // According to the specification, one of them.
@@ -43,6 +54,8 @@ type invstOrSec struct {
       Value string `xml:"value,attr"`
     } `xml:"other"`
   } `xml:"identifiers"`
+  DebtSec debtSec `xml:"debtSec"`
+  SecurityLending securityLending `xml:"securityLending"`
   DerivativeInfo struct {
     FwdDeriv struct {
       DerivCat string `xml:"derivCat,attr"`
@@ -62,32 +75,64 @@ type invstOrSec struct {
   } `xml:"derivativeInfo"`
 }
 
+// Subset of the fund-level information.
+type fundInfo struct {
+  // Kept as a string as it can be "N/A".
+  NetAssets string `xml:"netAssets"`
+  Borrowers borrowers `xml:"borrowers"`
+  ReturnInfo returnInfo `xml:"returnInfo"`
+  Mon1Flow monthlyFlow `xml:"mon1Flow"`
+  Mon2Flow monthlyFlow `xml:"mon2Flow"`
+  Mon3Flow monthlyFlow `xml:"mon3Flow"`
+  riskInfo
+}
+
 type singleSubmission struct {
   XMLName xml.Name `xml:"edgarSubmission"`
   FormData struct {
     GenInfo struct {
       Name string `xml:"seriesName"`
       SeriesId string `xml:"seriesId"`
+      // Date of the end of the reporting period (YYYY-MM-DD).
+      RepPdDate string `xml:"repPdDate"`
     } `xml:"genInfo"`
+    FundInfo fundInfo `xml:"fundInfo"`
     InvstOrSecs struct {
       InvstOrSec []invstOrSec  `xml:"invstOrSec"`
     } `xml:"invstOrSecs"`
   } `xml:"formData"`
 }
 
-type IndexComponent struct {
-  Name string `json:"name"`
-  Id string `json:"id"`
-  IdType string `json:"id_type"`
-  Weight float32 `json:"weight"`
+func parseYesNo(v string) bool {
+  return strings.EqualFold(strings.TrimSpace(v), "Y")
+}
+
+// parseFloat32 returns 0 for unparseable values like "N/A".
+func parseFloat32(v string) float32 {
+  f, err := strconv.ParseFloat(strings.TrimSpace(v), 32)
+  if err != nil {
+    return 0
+  }
+  return float32(f)
 }
 
-type Index struct {
-  Name string `json:"name"`
-  SeriesId string `json:"series_id"`
-  FilingDate string `json:"filing_date"`
-  // Note: The components may add up to more than 100%.
-  Components []IndexComponent `json:"components"`
+// parseFloat64 returns 0 for unparseable values like "N/A".
+func parseFloat64(v string) float64 {
+  f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+  if err != nil {
+    return 0
+  }
+  return f
+}
+
+// Type used for identifiers that we generate for holdings without any identifier.
+const kSyntheticIdType = "synthetic"
+
+// syntheticIdentifier derives a deterministic identifier from the issuer's fields
+// so that the same holding gets the same identifier across filings.
+func syntheticIdentifier(c invstOrSec) string {
+  hash := sha256.Sum256([]byte(strings.Join([]string{c.Name, c.Lei, c.Title}, "|")))
+  return "SYN" + strings.ToUpper(hex.EncodeToString(hash[:8]))
 }
 
 func getIdentifier(c invstOrSec) (string, string) {
@@ -102,39 +147,59 @@ func getIdentifier(c invstOrSec) (string, string) {
 
   id := c.Identifiers.Other.Value
   if id == "" {
-    panic(fmt.Sprintf("No identifier found for %+v", c))
+    // Some holdings have no identifier at all. We keep them as they still count
+    // towards the index. They are reported by the validation.
+    return syntheticIdentifier(c), kSyntheticIdType
   }
 
   idType := c.Identifiers.Other.OtherDesc
   return id, strings.ToLower(idType)
 }
 
+func isDerivative(component invstOrSec) bool {
+  if component.DerivativeInfo.FutrDeriv.DerivCat != "" {
+    return true
+  }
+  if component.DerivativeInfo.FwdDeriv.DerivCat != "" {
+    return true
+  }
+  if component.DerivativeInfo.SwapDeriv.DerivCat != "" {
+    return true
+  }
+  if component.DerivativeInfo.OptionSwaptionWarrantDeriv.DerivCat != "" {
+    return true
+  }
+  if component.DerivativeInfo.OtherDeriv.DerivCat != "" {
+    return true
+  }
+
+  // This should be handled by the derivative checks above, but this is kept to be defensive.
+  return component.Identifiers.Other.OtherDesc == "CONTRACT_VANGUARD_ID"
+}
+
 func populateIndexFromSingleSubmission(submission singleSubmission, info SubmissionInfo) Index {
-  index := Index{submission.FormData.GenInfo.Name, submission.FormData.GenInfo.SeriesId, info.FilingDate, []IndexComponent{}}
-  for _, component := range submission.FormData.InvstOrSecs.InvstOrSec {
+  index := Index{Name: submission.FormData.GenInfo.Name, SeriesId: submission.FormData.GenInfo.SeriesId, FilingDate: info.FilingDate, AccessionNumber: info.AccessionNumber, ReportDate: submission.FormData.GenInfo.RepPdDate, Components: []IndexComponent{}}
+  derivativesWeight := 0.0
+  cashWeight := 0.0
+  for i, component := range submission.FormData.InvstOrSecs.InvstOrSec {
     // Ignore any derivative.
-    if component.DerivativeInfo.FutrDeriv.DerivCat != "" {
+    if isDerivative(component) {
+      derivativesWeight += float64(component.PctVal)
       continue
     }
-    if component.DerivativeInfo.FwdDeriv.DerivCat != "" {
-      continue
-    }
-    if component.DerivativeInfo.SwapDeriv.DerivCat != "" {
-      continue
-    }
-    if component.DerivativeInfo.OptionSwaptionWarrantDeriv.DerivCat != "" {
-      continue
-    }
-    if component.DerivativeInfo.OtherDeriv.DerivCat != "" {
-      continue
-    }
-
-    // This should be handled by the derivative checks above, but this is kept to be defensive.
-    if component.Identifiers.Other.OtherDesc == "CONTRACT_VANGUARD_ID" {
-      continue
+    if isCash(component) {
+      cashWeight += float64(component.PctVal)
     }
     id, idType := getIdentifier(component)
-    index.Components = append(index.Components, IndexComponent{component.Name, id, idType, component.PctVal})
+    indexComponent := IndexComponent{Name: component.Name, Id: id, IdType: idType, Weight: component.PctVal, Debt: getDebtInfo(component.DebtSec), Lending: getLendingInfo(component.SecurityLending)}
+    if aggregateDuplicates {
+      indexComponent.Lots = []ComponentLot{ComponentLot{Line: i + 1, Name: component.Name, Weight: component.PctVal}}
+    }
+    index.Components = append(index.Components, indexComponent)
+  }
+  if aggregateDuplicates {
+    index.Components = aggregateDuplicateComponents(index.Components)
+    index.AggregatedDuplicates = true
   }
   // Sort by weight descending, then Id ascending.
   slices.SortFunc(index.Components, func (a, b IndexComponent) int {
@@ -146,10 +211,15 @@ func populateIndexFromSingleSubmission(submission singleSubmission, info Submiss
     }
     return strings.Compare(a.Id, b.Id)
   })
+  index.Weights = computeWeightTotals(index, derivativesWeight, cashWeight)
+  index.Bonds = computeBondAnalytics(index)
+  fundInfo := submission.FormData.FundInfo
+  index.Lending = computeFundLending(index, parseFloat64(fundInfo.NetAssets), fundInfo.Borrowers)
+  index.Risk = getRiskMetrics(fundInfo.riskInfo)
   return index
 }
 
-func fetchSingleSubmission(c *edgar_client.EdgarClient, info SubmissionInfo) (Index, error) {
+func fetchSingleSubmission(c *edgar_client.EdgarClient, info SubmissionInfo) (Index, []MonthlyPerformance, error) {
   // Note: We convert companyId to int to trim the leading zero that are not needed.
   url := fmt.Sprintf(kUrlSingleSubmissionXml, info.Cik, info.AccessionNumber)
   fmt.Printf("About to query single submission: %s\n", url)
@@ -157,13 +227,13 @@ func fetchSingleSubmission(c *edgar_client.EdgarClient, info SubmissionInfo) (In
   submission := singleSubmission{}
   err := c.GetXml(url, &submission)
   if err != nil {
-    return Index{}, nil
+    return Index{}, []MonthlyPerformance{}, fmt.Errorf("fetching %s: %w", url, err)
   }
   seriesId := submission.FormData.GenInfo.SeriesId
   etfName, _ := seriesToEtfs[IndexId{info.Cik, seriesId}]
   fmt.Printf("Fetched single submission for %s (seriesId=%s, etfName=%s)\n", submission.FormData.GenInfo.Name, seriesId, etfName)
 
-  return populateIndexFromSingleSubmission(submission, info), nil
+  return populateIndexFromSingleSubmission(submission, info), populatePerformanceFromSingleSubmission(submission, info), nil
 }
 
 type AllSubmissions struct {
@@ -195,7 +265,7 @@ func fetchAllSubmissions(c *edgar_client.EdgarClient, cik int) ([]SubmissionInfo
   v := AllSubmissions{}
   err := c.GetJson(url, &v)
   if err != nil {
-    return []SubmissionInfo{}, nil
+    return []SubmissionInfo{}, fmt.Errorf("fetching %s: %w", url, err)
   }
   // TODO: Add some debugging mode as this is verbose: fmt.Printf("all submissions for %+v\n", v)
 
@@ -215,103 +285,6 @@ func fetchAllSubmissions(c *edgar_client.EdgarClient, cik int) ([]SubmissionInfo
   return submissionInfos, nil
 }
 
-type ValidationResult struct {
-  etfName string // empty if unknown (and there will be a warning).
-  warnings []string
-  errors []string
-}
-
-func (r *ValidationResult) addError(err string) {
-  r.errors = append(r.errors, err)
-}
-
-func (r *ValidationResult) addWarning(err string) {
-  r.warnings = append(r.warnings, err)
-}
-
-func (r ValidationResult) dump() {
-  hasErrors := len(r.errors) != 0
-  hasWarnings := len(r.warnings) != 0
-  if !hasErrors && !hasWarnings {
-    // No issue, nothing to report.
-    return
-  }
-  fmt.Printf("***************** Validation report for %s *****************\n", r.etfName)
-  if hasErrors {
-    fmt.Printf("Errors:\n")
-    for _, err := range r.errors {
-      fmt.Printf("  %s\n", err)
-    }
-    fmt.Printf("\n\n")
-  }
-  if hasWarnings {
-    fmt.Printf("Warnings:\n")
-    for _, warning := range r.warnings {
-      fmt.Printf("  %s\n", warning)
-    }
-    fmt.Printf("\n\n")
-  }
-}
-
-func validateIndex(cik int, index Index) ValidationResult {
-  res := ValidationResult{"", []string{}, []string{}}
-  if index.Name == "" || index.Name == "N/A" {
-    // TODO: Link to index.
-    res.addError("Index is missing name")
-  }
-  if index.SeriesId == "" {
-    res.addError(fmt.Sprintf("Index %s is missing seriesName", index.Name))
-  }
-  etfName, ok := seriesToEtfs[IndexId{cik, index.SeriesId}]
-  if !ok {
-    res.addWarning(fmt.Sprintf("Index %s doesn't have a corresponding ETF in our map", index.Name))
-  }
-  if etfName == "" {
-    res.addError(fmt.Sprintf("Empty name in for index %s in our map", index.Name))
-  }
-  res.etfName = etfName
-
-  // If we have errors, it's not worth continuing.
-  // Doing so, ensure that we have an ETF name to report.
-  if len(res.errors) > 0 || len(res.warnings) > 0 {
-    return res
-  }
-
-  for _, component := range index.Components {
-    if component.Name == "N/A" || component.Name == "" {
-      res.addError(fmt.Sprintf("ETF %s has a component with no name=%s, id=%s", res.etfName, component.Name, component.Id))
-    }
-
-    if component.Id == "N/A" || component.Id == "" {
-      res.addError(fmt.Sprintf("ETF %s has a component with no id, name=%s, id=%s", res.etfName, component.Name, component.Id))
-    }
-
-    if component.IdType == "N/A" || component.IdType == "" {
-      res.addError(fmt.Sprintf("ETF %s has a component with no idType name=%s, id=%s, id_type=%s", res.etfName, component.Name, component.Id, component.IdType))
-    } else {
-      // Validate that we know the type. This is only a warning as it's mostly a signal for the users.
-      knownTypes := map[string]bool {
-        "isin": true,
-        "ticker": true,
-        "sedol": true,
-        "faid": true,
-        "cins": true,
-        "cusip": true,
-        "vid": true,
-      }
-      known := knownTypes[component.IdType]
-      if !known {
-        res.addWarning(fmt.Sprintf("ETF %s has a component with an unknown idType name=%s, id=%s, id_type=%s", res.etfName, component.Name, component.Id, component.IdType))
-      }
-    }
-
-    if component.Weight < 0 {
-      res.addError(fmt.Sprintf("ETF %s has a component with negative weight, name=%s, id=%s", res.etfName, component.Name, component.Id))
-    }
-  }
-  return res
-}
-
 type IndexId struct {
   Cik int
   SeriesId string
@@ -321,21 +294,15 @@ var cikToEtfs = map[int][]string{}
 var seriesToEtfs = map[IndexId]string{}
 var ciks = []int{}
 
-// TODO: This needs to be shared with the tools dir.
-type StoredIndex struct {
-  SeriesId string `json:"series_id"`
-  Name string `json:"name"`
-}
-
 func initEtfs() error {
-  m := map[int][]StoredIndex{}
-  if err := readJsonFile(kStoredIndexFile, &m); err != nil {
+  registry, err := etf_data.ReadRegistry(os.DirFS("."))
+  if err != nil {
     return err
   }
 
-  for cik, indexes := range m {
+  for _, cik := range registry.Ciks() {
     ciks = append(ciks, cik)
-    for _, index := range indexes {
+    for _, index := range registry[cik] {
       etfs, _ := cikToEtfs[cik]
       etfs = append(etfs, index.Name)
       cikToEtfs[cik] = etfs
@@ -347,16 +314,9 @@ func initEtfs() error {
 }
 
 func readFetchedDate() FetchedDatesMap {
-  f, err := os.Open(kFetchedMapFile)
+  v, err := storage.ReadFetchState()
   if err != nil {
-    panic(fmt.Sprintf("Couldn't open date file, err=%+v", err))
-  }
-
-  v := FetchedDatesMap{}
-  decoder := json.NewDecoder(f)
-  err = decoder.Decode(&v)
-  if err != nil {
-    panic(fmt.Sprintf("Couldn't JSON decode the fetched date file, err=%+v", err))
+    panic(fmt.Sprintf("Couldn't read the fetched date file, err=%+v", err))
   }
   return v
 }
@@ -371,33 +331,28 @@ func readJsonFile(path string, v any) error {
   return decoder.Decode(v)
 }
 
+// writeToJsonFile atomically replaces `path`: an interrupted write leaves the previous content.
 func writeToJsonFile(path string, v any) error {
-  f, err := os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_TRUNC, 0644)
-  if err != nil {
-    return err
-  }
-
-  bytes, err := json.Marshal(v)
+  bytes, err := marshalDataJson(v)
   if err != nil {
     return err
   }
 
-  if _, err := f.Write(bytes); err != nil {
-    f.Close() // ignore error; Write error takes precedence
-    return err
-  }
-  if err := f.Close(); err != nil {
-    return err
-  }
-  return nil
+  return writeFileAtomically(path, bytes)
 }
 
+// FetchedDatesMap is stored as is in fetched_map.json: unlike the other files, it has no `schema_version`
+// as the existing readers decode it as a map keyed by CIK. Its version is in its schema's `$id`.
 type FetchedDatesMap map[int] FilingDateSpan
+
 type FilingDateSpan struct {
   // Both ends are inclusive so this represents the span: [Start, End]
   // If both are "", it's the empty span.
   Start string `json:"start"`
   End string `json:"end"`
+  // Accession numbers of the filings in the span that failed to fetch or to validate.
+  // They are fetched again by the next runs until they succeed.
+  Failed []string `json:"failed,omitempty"`
 }
 
 func (f FilingDateSpan) isEmpty() bool {
@@ -423,6 +378,21 @@ func (f *FilingDateSpan) update(start, end string) {
   }
 }
 
+// updateFailed replaces the failed accession numbers of `submissions`, which were fetched again, with `failed`.
+func (f *FilingDateSpan) updateFailed(submissions []SubmissionInfo, failed []string) {
+  res := []string{}
+  for _, accessionNumber := range f.Failed {
+    if !slices.ContainsFunc(submissions, func (info SubmissionInfo) bool { return info.AccessionNumber == accessionNumber }) {
+      res = append(res, accessionNumber)
+    }
+  }
+  res = append(res, failed...)
+  f.Failed = nil
+  if len(res) > 0 {
+    f.Failed = res
+  }
+}
+
 func filterFilingDates(infos []SubmissionInfo, fetchedDates FilingDateSpan) []SubmissionInfo {
   if fetchedDates.isEmpty() {
     return infos
@@ -430,7 +400,7 @@ func filterFilingDates(infos []SubmissionInfo, fetchedDates FilingDateSpan) []Su
 
   res := []SubmissionInfo{}
   for _, info := range infos {
-    if fetchedDates.spans(info.FilingDate) {
+    if fetchedDates.spans(info.FilingDate) && !slices.Contains(fetchedDates.Failed, info.AccessionNumber) {
       continue
     }
     res = append(res, info)
@@ -446,9 +416,8 @@ func buildIndexMap(cik int, fetchedDates FilingDateSpan) map[string][]Index {
   }
   etfs := cikToEtfs[cik]
   for _, etf := range etfs {
-    allFilePath := fmt.Sprintf("./data/all/%s.json", etf)
-    f, err := os.Open(allFilePath)
-    if err != nil {
+    v, err := storage.ReadHistory(etf)
+    if errors.Is(err, fs.ErrNotExist) {
       if etf == "VEXC" {
         // This is a new index as of 2025-09-01 so ignore a missing file.
         // TODO: Remove this check in 2026.
@@ -456,10 +425,6 @@ func buildIndexMap(cik int, fetchedDates FilingDateSpan) map[string][]Index {
       }
       panic(fmt.Sprintf("Error opening file for %s, err=%+v", etf, err))
     }
-
-    v := []Index{}
-    decoder := json.NewDecoder(f)
-    err = decoder.Decode(&v)
     if err != nil {
       panic(fmt.Sprintf("Couldn't JSON decode the file for %s, err=%+v", etf, err))
     }
@@ -469,12 +434,38 @@ func buildIndexMap(cik int, fetchedDates FilingDateSpan) map[string][]Index {
   return indexMap
 }
 
+// selectEtfs returns the ETFs from a comma separated list, or all our ETFs sorted by name if the list is empty.
+func selectEtfs(list string) []string {
+  if list != "" {
+    return strings.Split(list, ",")
+  }
+  etfs := []string{}
+  for _, cikEtfs := range cikToEtfs {
+    etfs = append(etfs, cikEtfs...)
+  }
+  slices.Sort(etfs)
+  return etfs
+}
+
+// readAllIndexes returns the stored indexes for `etf`, ordered from the newest to the oldest.
+func readAllIndexes(etf string) ([]Index, error) {
+  v, err := storage.ReadHistory(etf)
+  if err != nil {
+    return []Index{}, err
+  }
+  return v, nil
+}
+
 func initAll() error {
   // Init the ETF maps.
   if err := initEtfs(); err != nil {
     return err
   }
 
+  if err := loadValidationConfig(kValidationConfigFile); err != nil {
+    return fmt.Errorf("invalid validation config %s: %w", kValidationConfigFile, err)
+  }
+
   // Ensure that the output directories are present before fetching.
   if err := os.MkdirAll("data/latest", 0755); err != nil {
     return err
@@ -482,13 +473,124 @@ func initAll() error {
   if err := os.MkdirAll("data/all", 0755); err != nil {
     return err
   }
+  if err := os.MkdirAll("data/performance", 0755); err != nil {
+    return err
+  }
   return nil
 }
 
+// Commands, passed as the first argument (e.g. `go run . lending`).
+// Without any command, we fetch the new filings.
+var kCommands = map[string]func(args []string) error {
+  "by_filing": runByFiling,
+  "database": runDatabase,
+  "diff": runDiff,
+  "export": runExport,
+  "manifest": runManifest,
+  "publish": runPublish,
+  "lending": runLendingReport,
+  "risk": runRiskReport,
+  "validate": runValidate,
+}
+
 func main() {
   if err := initAll(); err != nil {
     panic(fmt.Sprintf("Initialization failed with err=%+v", err))
   }
+  if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+    command, ok := kCommands[os.Args[1]]
+    if !ok {
+      fmt.Printf("Unknown command %s\n", os.Args[1])
+      os.Exit(2)
+    }
+    if err := command(os.Args[2:]); err != nil {
+      // The issues were already reported.
+      issues := validationIssuesError{}
+      if errors.As(err, &issues) {
+        os.Exit(exitCode(issues.severity))
+      }
+      fmt.Printf("Error: %s failed (err=%+v)\n", os.Args[1], err)
+      os.Exit(1)
+    }
+    return
+  }
+
+  var jsonReportFlag = flag.String("json_report", "", "Path to write the validation report as JSON")
+  var junitReportFlag = flag.String("junit_report", "", "Path to write the validation report as JUnit XML")
+  flag.BoolVar(&aggregateDuplicates, "aggregate_duplicates", false, "Merge the components reported on several lines into a single component")
+  flag.BoolVar(&writeByFiling, "by_filing", false, "Also write each filing to data/by_filing/<ETF>/<filing_date>.json")
+  flag.StringVar(&historyFormat, "history_format", kFullHistory, "Format of the data/all files: full or delta (delta-encoded against the previous filing)")
+  flag.Parse()
+  if historyFormat != kFullHistory && historyFormat != kDeltaHistory {
+    fmt.Printf("Unknown -history_format %s, expected full or delta\n", historyFormat)
+    os.Exit(2)
+  }
+
+  // Only one run can write to the data directory at a time.
+  lock, err := acquireDataLock(kLockFile)
+  if err != nil {
+    fmt.Printf("Error: %+v\n", err)
+    os.Exit(1)
+  }
+  code := fetchAndReport(*jsonReportFlag, *junitReportFlag)
+  if err := lock.release(); err != nil {
+    fmt.Printf("Error: releasing the lock %s (err=%+v)\n", kLockFile, err)
+    code = max(code, 1)
+  }
+  os.Exit(code)
+}
+
+// fetchAndReport fetches the new filings, writes the validation reports and returns the exit code.
+func fetchAndReport(jsonReportPath, junitReportPath string) int {
+  summary := &RunSummary{}
+  err := fetch(summary)
+  summary.dump()
+  if reportErr := writeReports(summary, jsonReportPath, junitReportPath); reportErr != nil {
+    fmt.Printf("Error: writing the validation reports (err=%+v)\n", reportErr)
+    return 1
+  }
+  if err != nil {
+    fmt.Printf("Error: %+v\n", err)
+    return 1
+  }
+  return exitCode(summary.worstSeverity())
+}
+
+// writeCikData writes the data of `cik` to `batch` and updates the fetched dates for `submissions`.
+// The accession numbers in `failed` are the submissions that failed, to be fetched again.
+func writeCikData(batch StorageBatch, cik int, submissions []SubmissionInfo, failed []string, fetchedDateMap FetchedDatesMap, indexMap map[string][]Index, performanceMap map[string][]MonthlyPerformance) error {
+  for etfName, indexes := range indexMap {
+    // The indexes read from older files may predate the schema.
+    for i := range indexes {
+      indexes[i].SchemaVersion = kSchemaVersion
+    }
+    if err := batch.WriteHistory(etfName, indexes); err != nil {
+      return err
+    }
+  }
+  for etfName, months := range performanceMap {
+    if err := batch.WritePerformance(etfName, months); err != nil {
+      return err
+    }
+  }
+  // Update the fetched dates now that we've succeeded for this company.
+  fetchedDates := fetchedDateMap[cik]
+  fetchedDates.update(submissions[0].FilingDate, submissions[len(submissions) - 1].FilingDate)
+  fetchedDates.updateFailed(submissions, failed)
+  fetchedDateMap[cik] = fetchedDates
+  return batch.WriteFetchState(fetchedDateMap)
+}
+
+// fetch fetches the new filings and writes them to the data directory.
+// An ETF's new filings are only written if none of them has validation errors. The submissions that failed to
+// fetch and the filings of such an ETF are recorded as failed in fetched_map.json, so the next runs fetch them
+// again, while the other ETFs of the CIK are written.
+func fetch(summary *RunSummary) error {
+  // Before reading the data, complete the writes of an interrupted run.
+  if err := recoverFileBatch(kJournalFile); err != nil {
+    return err
+  }
+
   fetchedDateMap := readFetchedDate()
   fmt.Printf("FetchedMap: %+v\n", fetchedDateMap)
 
@@ -501,6 +603,7 @@ func main() {
   for _, cik := range ciks {
     fetchedDates := fetchedDateMap[cik]
     indexMap := buildIndexMap(cik, fetchedDates)
+    performanceMap := map[string][]MonthlyPerformance{}
     // Vanguard has a lot of submissions, unfortunately we don't know which ones are useful
     // before fetching them as we don't know if the submissions have an associated ETF...
     //
@@ -508,8 +611,11 @@ func main() {
     // Ideally we should replace with something better, like a per-seriesId search.
     submissions, err := fetchAllSubmissions(&c, cik)
     if err != nil {
-      fmt.Printf("Error fetching/parsing all submissions JSON, err=%+v\n", err)
-      return
+      fmt.Printf("Error fetching/parsing all submissions JSON for cik=%d, err=%+v\n", cik, err)
+      res := ValidationResult{"", cik, "", "", []string{}, []string{fmt.Sprintf("Failed to fetch the submissions: %+v", err)}, []IndexComponent{}}
+      res.dump()
+      summary.add(res)
+      continue
     }
     submissions = filterFilingDates(submissions, fetchedDates)
     if len(submissions) == 0 {
@@ -544,19 +650,56 @@ func main() {
     }
     // TODO: Add a debugging mode as this is verbose: fmt.Printf("submissions to fetch = %+v", submissions)
 
+    // Accession numbers of the submissions to fetch again.
+    failed := []string{}
+    // The ETFs with validation errors, and the accession numbers of each ETF's valid filings.
+    failedEtfs := map[string]bool{}
+    etfSubmissions := map[string][]string{}
     for _, submission := range submissions {
-      index, err := fetchSingleSubmission(&c, submission)
+      index, months, err := fetchSingleSubmission(&c, submission)
       if err != nil {
         fmt.Printf("Error fetching/parsing single XML submission for %+v, err=%+v\n", submission, err)
+        res := ValidationResult{"", cik, "", submission.FilingDate, []string{}, []string{fmt.Sprintf("Failed to fetch the submission %s: %+v", submission.AccessionNumber, err)}, []IndexComponent{}}
+        res.dump()
+        summary.add(res)
+        failed = append(failed, submission.AccessionNumber)
+        continue
       }
       res := validateIndex(cik, index)
+      if previous, ok := findPreviousIndex(indexMap[res.etfName], index.FilingDate); ok && res.etfName != "" {
+        validateAgainstPrevious(&res, previous, index)
+      }
       res.dump()
+      summary.add(res)
+      // The filings without an ETF aren't ours, so they aren't fetched again.
       if res.etfName == "" {
         continue
       }
+      if res.severity() == kSeverityError {
+        failed = append(failed, submission.AccessionNumber)
+        failedEtfs[res.etfName] = true
+        continue
+      }
+      etfSubmissions[res.etfName] = append(etfSubmissions[res.etfName], submission.AccessionNumber)
       existingIndexes := indexMap[res.etfName]
       existingIndexes = append(existingIndexes, index)
       indexMap[res.etfName] = existingIndexes
+
+      existingPerformance, ok := performanceMap[res.etfName]
+      if !ok {
+        existingPerformance, err = readPerformance(res.etfName)
+        if err != nil {
+          return fmt.Errorf("reading the performance of %s: %w", res.etfName, err)
+        }
+      }
+      performanceMap[res.etfName] = mergePerformance(existingPerformance, months)
+    }
+    for _, etfName := range slices.Sorted(maps.Keys(failedEtfs)) {
+      // The ETF's history is kept as is and all its new filings are fetched again, so they stay in order.
+      fmt.Printf("Error: some filings of %s have validation errors, not writing its data for cik=%d\n", etfName, cik)
+      delete(indexMap, etfName)
+      delete(performanceMap, etfName)
+      failed = append(failed, etfSubmissions[etfName]...)
     }
 
     // Sort the indexes from newest to oldest.
@@ -571,21 +714,15 @@ func main() {
     // TODO: Do we want to preprocess more of the data (e.g. by standardizing tickers to their name)?
     // This could be done using: https://github.com/JerBouma/FinanceDatabase/tree/main
 
-    for etfName, indexes := range indexMap {
-      allFilePath := fmt.Sprintf("./data/all/%s.json", etfName)
-      if err := writeToJsonFile(allFilePath, indexes); err != nil {
-        fmt.Printf("Error: writing to file %s (err=%+v)\n", allFilePath, err)
-        return
-      }
-      latestFilePath := fmt.Sprintf("./data/latest/%s.json", etfName)
-      if err := writeToJsonFile(latestFilePath, indexes[0]); err != nil {
-        fmt.Printf("Error: writing to file %s (err=%+v)\n", allFilePath, err)
-        return
-      }
+    // All the data of the CIK is committed together, with the fetched dates last.
+    batch := storage.NewBatch()
+    if err := writeCikData(batch, cik, submissions, failed, fetchedDateMap, indexMap, performanceMap); err != nil {
+      batch.Discard()
+      return err
+    }
+    if err := batch.Commit(); err != nil {
+      return fmt.Errorf("committing the data of cik=%d: %w", cik, err)
     }
-    // Update the fetched dates now that we've succeeded for this company.
-    fetchedDates.update(submissions[0].FilingDate, submissions[len(submissions) - 1].FilingDate)
-    fetchedDateMap[cik] = fetchedDates
-    writeToJsonFile(kFetchedMapFile, fetchedDateMap)
   }
+  return nil
 }
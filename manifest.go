@@ -0,0 +1,345 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+  "flag"
+  "fmt"
+  "io/fs"
+  "maps"
+  "os"
+  "path/filepath"
+  "reflect"
+  "runtime/debug"
+  "slices"
+  "strings"
+)
+
+// Name of the manifest in the data directory, listing the other data files.
+const kManifestFileName = "manifest.json"
+
+// Manifest lists the data files (data/manifest.json) so that mirrors can verify them and detect the
+// changed ETFs without diffing the files.
+type Manifest struct {
+  SchemaVersion int `json:"schema_version"`
+  // Keyed by path relative to the data directory, e.g. "all/VOO.json".
+  Files map[string]ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+  Sha256 string `json:"sha256"`
+  Size int64 `json:"size"`
+  // The coverage metadata is only present for the files of an ETF.
+  Etf string `json:"etf,omitempty"`
+  // Number of filings, or of months for the performance files.
+  Filings int `json:"filings,omitempty"`
+  FirstFilingDate string `json:"first_filing_date,omitempty"`
+  LastFilingDate string `json:"last_filing_date,omitempty"`
+  // Accession numbers of the filings, when known, in the file's order.
+  AccessionNumbers []string `json:"accession_numbers,omitempty"`
+  // Version of the tool that wrote the file, see describeToolVersion, or kUnknownToolVersion.
+  ToolVersion string `json:"tool_version"`
+}
+
+// Version of the tool, stamped with `go build -ldflags "-X main.stampedToolVersion=<version>"` when the
+// build doesn't record the VCS revision, e.g. outside of the git checkout. It takes precedence over the build info.
+var stampedToolVersion = ""
+
+// Version recorded for the files whose writer isn't identified.
+const kUnknownToolVersion = "unknown"
+
+// describeToolVersion returns the version of the binary and whether it identifies the source: stamped, or
+// with the VCS revision. `go run` doesn't record the revision.
+func describeToolVersion() (string, bool) {
+  if stampedToolVersion != "" {
+    return stampedToolVersion, true
+  }
+  info, ok := debug.ReadBuildInfo()
+  if !ok {
+    return kUnknownToolVersion, false
+  }
+  version := info.Main.Path + " " + info.Main.Version
+  revision, modified := "", false
+  for _, setting := range info.Settings {
+    switch setting.Key {
+      case "vcs.revision":
+        revision = setting.Value
+      case "vcs.modified":
+        modified = setting.Value == "true"
+    }
+  }
+  if revision == "" {
+    return version, false
+  }
+  version += " " + revision
+  if modified {
+    version += "+dirty"
+  }
+  return version, true
+}
+
+// isManifestFile returns whether the file at `relPath` (in the data directory) is listed in the manifest:
+// the JSON files except the manifest itself and the hidden files (journal, lock and temporary files).
+func isManifestFile(relPath string) bool {
+  return relPath != kManifestFileName && strings.HasSuffix(relPath, ".json") && !strings.HasPrefix(filepath.Base(relPath), ".")
+}
+
+// setFilings sets the coverage of the `dates` and `accessionNumbers` (in the same order) of `etf`.
+func (e *ManifestEntry) setFilings(etf string, dates []string, accessionNumbers []string) {
+  e.Etf = etf
+  e.Filings = len(dates)
+  if len(dates) > 0 {
+    e.FirstFilingDate = slices.Min(dates)
+    e.LastFilingDate = slices.Max(dates)
+  }
+  for _, accessionNumber := range accessionNumbers {
+    if accessionNumber != "" {
+      e.AccessionNumbers = append(e.AccessionNumbers, accessionNumber)
+    }
+  }
+}
+
+func (e *ManifestEntry) setIndexes(etf string, indexes []Index) {
+  dates := []string{}
+  accessionNumbers := []string{}
+  for _, index := range indexes {
+    dates = append(dates, index.FilingDate)
+    accessionNumbers = append(accessionNumbers, index.AccessionNumber)
+  }
+  e.setFilings(etf, dates, accessionNumbers)
+}
+
+// describeDataFile returns the manifest entry of the file at `relPath` with the content `bytes`.
+func describeDataFile(relPath string, bytes []byte, version string) (ManifestEntry, error) {
+  sum := sha256.Sum256(bytes)
+  res := ManifestEntry{Sha256: hex.EncodeToString(sum[:]), Size: int64(len(bytes)), ToolVersion: version}
+  parts := strings.Split(filepath.ToSlash(relPath), "/")
+  name := strings.TrimSuffix(parts[len(parts) - 1], ".json")
+  switch {
+    case len(parts) == 2 && parts[0] == "all":
+      etf := strings.TrimSuffix(name, ".delta")
+      indexes, err := etf_data.DecodeHistory(bytes, etf != name)
+      if err != nil {
+        return res, err
+      }
+      res.setIndexes(etf, indexes)
+    case len(parts) == 2 && parts[0] == "latest", len(parts) == 3 && parts[0] == "by_filing" && parts[2] != kFilingsIndexFile:
+      index := Index{}
+      if err := json.Unmarshal(bytes, &index); err != nil {
+        return res, err
+      }
+      etf := name
+      if parts[0] == "by_filing" {
+        etf = parts[1]
+      }
+      res.setIndexes(etf, []Index{index})
+    case len(parts) == 3 && parts[0] == "by_filing":
+      filingsIndex := FilingsIndex{}
+      if err := json.Unmarshal(bytes, &filingsIndex); err != nil {
+        return res, err
+      }
+      dates := []string{}
+      accessionNumbers := []string{}
+      for _, filing := range filingsIndex.Filings {
+        dates = append(dates, filing.FilingDate)
+        accessionNumbers = append(accessionNumbers, filing.AccessionNumber)
+      }
+      res.setFilings(parts[1], dates, accessionNumbers)
+    case len(parts) == 2 && parts[0] == "performance":
+      months := []MonthlyPerformance{}
+      if err := json.Unmarshal(bytes, &months); err != nil {
+        return res, err
+      }
+      dates := []string{}
+      for _, month := range months {
+        dates = append(dates, month.FilingDate)
+      }
+      res.setFilings(name, dates, nil)
+  }
+  return res, nil
+}
+
+// buildManifest lists the files of `dataDir`. The entries of `previous` with the same checksum are kept,
+// with the version of the tool that wrote them. The others get `version`.
+func buildManifest(dataDir string, previous Manifest, version string) (Manifest, error) {
+  res := Manifest{kSchemaVersion, map[string]ManifestEntry{}}
+  err := filepath.WalkDir(dataDir, func (path string, d fs.DirEntry, err error) error {
+    if err != nil || d.IsDir() {
+      return err
+    }
+    relPath, err := filepath.Rel(dataDir, path)
+    if err != nil {
+      return err
+    }
+    relPath = filepath.ToSlash(relPath)
+    if !isManifestFile(relPath) {
+      return nil
+    }
+    bytes, err := os.ReadFile(path)
+    if err != nil {
+      return err
+    }
+    entry, err := describeDataFile(relPath, bytes, version)
+    if err != nil {
+      return fmt.Errorf("reading %s: %w", path, err)
+    }
+    if old, ok := previous.Files[relPath]; ok && old.Sha256 == entry.Sha256 {
+      entry.ToolVersion = old.ToolVersion
+    }
+    res.Files[relPath] = entry
+    return nil
+  })
+  return res, err
+}
+
+// readManifest reads the manifest of `dataDir`.
+func readManifest(dataDir string) (Manifest, error) {
+  res := Manifest{}
+  if err := readJsonFile(filepath.Join(dataDir, kManifestFileName), &res); err != nil {
+    return res, err
+  }
+  if res.Files == nil {
+    res.Files = map[string]ManifestEntry{}
+  }
+  return res, nil
+}
+
+// stageManifest stages the manifest of `dataDir` updated with the files staged in `batch`.
+// Without a manifest, the whole directory is listed first.
+// If the version of the binary isn't identified (see describeToolVersion), the files keep the version of their
+// previous entry, or get kUnknownToolVersion.
+func stageManifest(batch *fileBatch, dataDir string) error {
+  version, identified := describeToolVersion()
+  if !identified {
+    version = kUnknownToolVersion
+  }
+  manifest, err := readManifest(dataDir)
+  if errors.Is(err, fs.ErrNotExist) {
+    manifest, err = buildManifest(dataDir, Manifest{}, version)
+  }
+  if err != nil {
+    return err
+  }
+
+  for _, file := range batch.files {
+    relPath, err := filepath.Rel(dataDir, file.Target)
+    if err != nil {
+      return err
+    }
+    relPath = filepath.ToSlash(relPath)
+    if !isManifestFile(relPath) {
+      continue
+    }
+    if file.Temp == "" {
+      delete(manifest.Files, relPath)
+      continue
+    }
+    bytes, err := os.ReadFile(file.Temp)
+    if err != nil {
+      return err
+    }
+    entry, err := describeDataFile(relPath, bytes, version)
+    if err != nil {
+      return fmt.Errorf("describing %s: %w", file.Target, err)
+    }
+    if old, ok := manifest.Files[relPath]; ok && !identified {
+      entry.ToolVersion = old.ToolVersion
+    }
+    manifest.Files[relPath] = entry
+  }
+
+  path := filepath.Join(dataDir, kManifestFileName)
+  if err := batch.add(path, kManifestSchema, manifest); err != nil {
+    return fmt.Errorf("writing to file %s: %w", path, err)
+  }
+  return nil
+}
+
+// validateManifest checks the manifest of `dataDir` against its files.
+func validateManifest(dataDir string, summary *RunSummary) error {
+  res := ValidationResult{"", 0, "", "", []string{}, []string{}, []IndexComponent{}}
+  defer func() {
+    if len(res.errors) > 0 || len(res.warnings) > 0 {
+      summary.add(res)
+    }
+  }()
+
+  manifestPath := filepath.Join(dataDir, kManifestFileName)
+  manifest, err := readManifest(dataDir)
+  if errors.Is(err, fs.ErrNotExist) {
+    res.addWarning(fmt.Sprintf("There is no manifest %s, run the manifest command to create it", manifestPath))
+    return nil
+  }
+  if err != nil {
+    return fmt.Errorf("reading %s: %w", manifestPath, err)
+  }
+  actual, err := buildManifest(dataDir, manifest, "")
+  if err != nil {
+    return err
+  }
+  for _, relPath := range slices.Sorted(maps.Keys(actual.Files)) {
+    expected, ok := manifest.Files[relPath]
+    if !ok {
+      res.addError(fmt.Sprintf("File %s isn't listed in %s", filepath.Join(dataDir, relPath), manifestPath))
+      continue
+    }
+    if entry := actual.Files[relPath]; !reflect.DeepEqual(entry, expected) {
+      res.addError(fmt.Sprintf("File %s doesn't match its entry in %s (sha256=%s, size=%d but listed with sha256=%s, size=%d)", filepath.Join(dataDir, relPath), manifestPath, entry.Sha256, entry.Size, expected.Sha256, expected.Size))
+    }
+  }
+  for _, relPath := range slices.Sorted(maps.Keys(manifest.Files)) {
+    if _, ok := actual.Files[relPath]; !ok {
+      res.addError(fmt.Sprintf("File %s is listed in %s but doesn't exist", filepath.Join(dataDir, relPath), manifestPath))
+    }
+  }
+  return nil
+}
+
+// runManifest rebuilds the manifest from the data directory, e.g. to create it or after editing the files by hand.
+func runManifest(args []string) error {
+  flags := flag.NewFlagSet("manifest", flag.ExitOnError)
+  restamp := flags.Bool("restamp", false, "Give every entry the version of this binary instead of keeping the version of the unchanged files")
+  versionFlag := flags.String("tool_version", "", "Version to record instead of the binary's, e.g. \"unknown\" for files whose writer isn't known")
+  flags.Parse(args)
+
+  version, identified := describeToolVersion()
+  if *versionFlag != "" {
+    version, identified = *versionFlag, true
+  }
+  if !identified {
+    return fmt.Errorf("the version of this binary (%s) has no VCS revision, build it with `go build` in the git checkout or stamp it with -ldflags \"-X main.stampedToolVersion=<version>\"", version)
+  }
+
+  lock, err := acquireDataLock(kLockFile)
+  if err != nil {
+    return err
+  }
+  defer lock.release() // ignore error; the lock becomes stale anyway
+  if err := recoverFileBatch(kJournalFile); err != nil {
+    return err
+  }
+
+  previous, err := readManifest("./data")
+  if err != nil && !errors.Is(err, fs.ErrNotExist) {
+    return err
+  }
+  if *restamp {
+    previous = Manifest{}
+  }
+  manifest, err := buildManifest("./data", previous, version)
+  if err != nil {
+    return err
+  }
+  path := filepath.Join("./data", kManifestFileName)
+  if err := validateSchema(kManifestSchema, manifest); err != nil {
+    return err
+  }
+  if err := writeToJsonFile(path, manifest); err != nil {
+    return err
+  }
+  fmt.Printf("Wrote %s listing %d files\n", path, len(manifest.Files))
+  return nil
+}
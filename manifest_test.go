@@ -0,0 +1,264 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+// countIssues returns the number of errors and warnings in `summary`.
+func countIssues(summary *RunSummary) (int, int) {
+  errors, warnings := 0, 0
+  for _, res := range summary.results {
+    errors += len(res.errors)
+    warnings += len(res.warnings)
+  }
+  return errors, warnings
+}
+
+func TestDescribeDataFile(t *testing.T) {
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", AccessionNumber: "000110465925103792", Components: []IndexComponent{stock}},
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+  history, err := marshalDataJson(indexes)
+  if err != nil {
+    t.Fatalf("Failed to marshal the history (err=%+v)", err)
+  }
+  delta, err := encodeDeltaHistory(indexes)
+  if err != nil {
+    t.Fatalf("Failed to encode the history (err=%+v)", err)
+  }
+  deltaHistory, err := marshalDataJson(delta)
+  if err != nil {
+    t.Fatalf("Failed to marshal the delta history (err=%+v)", err)
+  }
+  latest, err := marshalDataJson(indexes[0])
+  if err != nil {
+    t.Fatalf("Failed to marshal the filing (err=%+v)", err)
+  }
+  filingsIndex, err := marshalDataJson(buildFilingsIndex("VXF", indexes))
+  if err != nil {
+    t.Fatalf("Failed to marshal the filings index (err=%+v)", err)
+  }
+
+  tt := []struct {
+    name string
+    relPath string
+    bytes []byte
+    expected ManifestEntry
+  } {
+    {"History", "all/VXF.json", history, ManifestEntry{Etf: "VXF", Filings: 2, FirstFilingDate: kDate, LastFilingDate: "2025-02-01", AccessionNumbers: []string{"000110465925103792"}}},
+    {"Delta history", "all/VXF.delta.json", deltaHistory, ManifestEntry{Etf: "VXF", Filings: 2, FirstFilingDate: kDate, LastFilingDate: "2025-02-01", AccessionNumbers: []string{"000110465925103792"}}},
+    {"Latest", "latest/VXF.json", latest, ManifestEntry{Etf: "VXF", Filings: 1, FirstFilingDate: "2025-02-01", LastFilingDate: "2025-02-01", AccessionNumbers: []string{"000110465925103792"}}},
+    {"Filing", "by_filing/VXF/2025-02-01.json", latest, ManifestEntry{Etf: "VXF", Filings: 1, FirstFilingDate: "2025-02-01", LastFilingDate: "2025-02-01", AccessionNumbers: []string{"000110465925103792"}}},
+    {"Filings index", "by_filing/VXF/filings.json", filingsIndex, ManifestEntry{Etf: "VXF", Filings: 2, FirstFilingDate: kDate, LastFilingDate: "2025-02-01", AccessionNumbers: []string{"000110465925103792"}}},
+    {"Performance", "performance/VXF.json", []byte(`[{"filing_date":"2025-02-01"},{"filing_date":"2025-01-01"},{"filing_date":"2024-12-01"}]`), ManifestEntry{Etf: "VXF", Filings: 3, FirstFilingDate: "2024-12-01", LastFilingDate: "2025-02-01"}},
+    {"Other file", "fetched_map.json", []byte(`{}`), ManifestEntry{}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      entry, err := describeDataFile(tc.relPath, tc.bytes, "test")
+      if err != nil {
+        t.Errorf("Failed to describe %s (err=%+v)", tc.relPath, err)
+        return
+      }
+      if len(entry.Sha256) != 64 || entry.Size != int64(len(tc.bytes)) || entry.ToolVersion != "test" {
+        t.Errorf("Unexpected checksum, size or version in %+v", entry)
+        return
+      }
+      entry.Sha256, entry.Size, entry.ToolVersion = "", 0, ""
+      if !reflect.DeepEqual(entry, tc.expected) {
+        t.Errorf("Mismatched entry, expected=%+v but got=%+v", tc.expected, entry)
+      }
+    })
+  }
+}
+
+func TestManifest(t *testing.T) {
+  dataDir := t.TempDir()
+  journalPath := filepath.Join(dataDir, ".pending_writes.json")
+  if err := os.MkdirAll(filepath.Join(dataDir, "all"), 0755); err != nil {
+    t.Fatalf("Failed to create the data directory (err=%+v)", err)
+  }
+  historyPath := filepath.Join(dataDir, "all", "VXF.json")
+  otherPath := filepath.Join(dataDir, "all", "VTI.json")
+  stock := IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.5}
+  indexes := []Index{
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{stock}},
+    Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock}},
+  }
+  // Writes the history of VXF with a new manifest.
+  stage := func () error {
+    os.Remove(filepath.Join(dataDir, kManifestFileName)) // ignore error; rebuilt by stageManifest
+    batch := newFileBatch(journalPath)
+    if err := stageHistory(batch, dataDir, "VXF", indexes); err != nil {
+      return err
+    }
+    if err := stageManifest(batch, dataDir); err != nil {
+      return err
+    }
+    return batch.commit()
+  }
+
+  // No manifest yet.
+  summary := &RunSummary{}
+  if err := validateManifest(dataDir, summary); err != nil {
+    t.Errorf("Failed to validate (err=%+v)", err)
+    return
+  }
+  if _, warnings := countIssues(summary); warnings != 1 {
+    t.Errorf("Expected a warning without manifest but got %+v", summary)
+    return
+  }
+
+  tt := []struct {
+    name string
+    // Changes the data directory after the manifest is written.
+    change func () error
+    errors int
+  } {
+    {"Consistent", func () error { return nil }, 0},
+    {"Modified file", func () error { return writeToJsonFile(historyPath, indexes[:1]) }, 1},
+    {"Unlisted file", func () error { return writeToJsonFile(otherPath, indexes[:1]) }, 1},
+    {"Missing file", func () error { return os.Remove(historyPath) }, 1},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      os.Remove(otherPath) // ignore error; only exists after the "Unlisted file" case
+      if err := stage(); err != nil {
+        t.Errorf("Failed to write the data (err=%+v)", err)
+        return
+      }
+      manifest, err := readManifest(dataDir)
+      if err != nil || manifest.Files["all/VXF.json"].Filings != len(indexes) {
+        t.Errorf("Unexpected manifest %+v (err=%+v)", manifest, err)
+        return
+      }
+
+      if err := tc.change(); err != nil {
+        t.Errorf("Failed to change the data (err=%+v)", err)
+        return
+      }
+      summary := &RunSummary{}
+      if err := validateManifest(dataDir, summary); err != nil {
+        t.Errorf("Failed to validate (err=%+v)", err)
+        return
+      }
+      if errors, _ := countIssues(summary); errors != tc.errors {
+        t.Errorf("Expected %d errors but got %+v", tc.errors, summary)
+      }
+    })
+  }
+
+  // Switching to the delta format replaces the entry of the full history.
+  if err := stage(); err != nil {
+    t.Errorf("Failed to write the data (err=%+v)", err)
+    return
+  }
+  historyFormat = kDeltaHistory
+  defer func() { historyFormat = kFullHistory }()
+  batch := newFileBatch(journalPath)
+  if err := stageHistory(batch, dataDir, "VXF", indexes); err != nil {
+    t.Errorf("Failed to stage the history (err=%+v)", err)
+    return
+  }
+  if err := stageManifest(batch, dataDir); err != nil {
+    t.Errorf("Failed to stage the manifest (err=%+v)", err)
+    return
+  }
+  if err := batch.commit(); err != nil {
+    t.Errorf("Failed to commit (err=%+v)", err)
+    return
+  }
+  manifest, err := readManifest(dataDir)
+  if _, ok := manifest.Files["all/VXF.json"]; err != nil || ok || manifest.Files["all/VXF.delta.json"].Filings != len(indexes) {
+    t.Errorf("Expected only the delta history in %+v (err=%+v)", manifest, err)
+    return
+  }
+  summary = &RunSummary{}
+  if err := validateManifest(dataDir, summary); err != nil {
+    t.Errorf("Failed to validate (err=%+v)", err)
+    return
+  }
+  if errors, _ := countIssues(summary); errors != 0 {
+    t.Errorf("Expected a consistent manifest but got %+v", summary)
+  }
+}
+
+func TestRunManifest(t *testing.T) {
+  t.Chdir(t.TempDir())
+  if err := os.MkdirAll(filepath.Join("data", "all"), 0755); err != nil {
+    t.Fatalf("Failed to create the data directory (err=%+v)", err)
+  }
+  indexes := []Index{Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}}
+  if err := writeToJsonFile(filepath.Join("data", "all", "VXF.json"), indexes); err != nil {
+    t.Fatalf("Failed to write the history (err=%+v)", err)
+  }
+  // Test binaries don't record the VCS revision.
+  if err := runManifest([]string{}); err == nil {
+    t.Errorf("Expected a binary without revision to be rejected")
+    return
+  }
+
+  defer func() { stampedToolVersion = "" }()
+  tt := []struct {
+    name string
+    version string
+    args []string
+    expected string
+  } {
+    {"New entry", "v1", []string{}, "v1"},
+    {"Unchanged entry", "v2", []string{}, "v1"},
+    {"Restamped", "v3", []string{"-restamp"}, "v3"},
+    {"Given version", "v3", []string{"-restamp", "-tool_version", kUnknownToolVersion}, kUnknownToolVersion},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      stampedToolVersion = tc.version
+      if err := runManifest(tc.args); err != nil {
+        t.Errorf("Failed to write the manifest (err=%+v)", err)
+        return
+      }
+      manifest, err := readManifest("data")
+      if actual := manifest.Files["all/VXF.json"].ToolVersion; err != nil || actual != tc.expected {
+        t.Errorf("Expected the version %s but got %s (err=%+v)", tc.expected, actual, err)
+      }
+    })
+  }
+}
+
+func TestStageManifestUnknownVersion(t *testing.T) {
+  dataDir := t.TempDir()
+  if err := os.MkdirAll(filepath.Join(dataDir, "all"), 0755); err != nil {
+    t.Fatalf("Failed to create the data directory (err=%+v)", err)
+  }
+  manifest := Manifest{kSchemaVersion, map[string]ManifestEntry{"all/VXF.json": ManifestEntry{Sha256: "0", ToolVersion: "v1"}}}
+  if err := writeToJsonFile(filepath.Join(dataDir, kManifestFileName), manifest); err != nil {
+    t.Fatalf("Failed to write the manifest (err=%+v)", err)
+  }
+
+  // Test binaries don't record the VCS revision.
+  indexes := []Index{Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}}
+  batch := newFileBatch(filepath.Join(dataDir, ".pending_writes.json"))
+  for _, etf := range []string{"VXF", "VTI"} {
+    if err := stageHistory(batch, dataDir, etf, indexes); err != nil {
+      t.Fatalf("Failed to stage the history (err=%+v)", err)
+    }
+  }
+  if err := stageManifest(batch, dataDir); err != nil {
+    t.Fatalf("Failed to stage the manifest (err=%+v)", err)
+  }
+  if err := batch.commit(); err != nil {
+    t.Fatalf("Failed to commit (err=%+v)", err)
+  }
+  manifest, err := readManifest(dataDir)
+  if err != nil || manifest.Files["all/VXF.json"].ToolVersion != "v1" || manifest.Files["all/VTI.json"].ToolVersion != kUnknownToolVersion {
+    t.Errorf("Expected the previous version or %s but got %+v (err=%+v)", kUnknownToolVersion, manifest.Files, err)
+  }
+}
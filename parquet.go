@@ -0,0 +1,170 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+
+  "github.com/parquet-go/parquet-go"
+)
+
+// The Parquet export is written with github.com/parquet-go/parquet-go. The rows are streamed to the file in
+// row groups of at most kParquetRowGroupRows rows, whose columns are split in pages of about
+// kParquetPageBufferSize bytes, so a long history is never held in memory as a whole. The files are uncompressed.
+
+// Maximum number of rows of a row group. The writer buffers a row group in memory before writing it.
+const kParquetRowGroupRows = 64 * 1024
+// Size of the buffers where the pages of a column are encoded, which bounds the size of the pages.
+const kParquetPageBufferSize = 256 * 1024
+
+// parquetHolding is a row of the Parquet export.
+type parquetHolding struct {
+  Etf string `parquet:"etf"`
+  SeriesId string `parquet:"series_id"`
+  FilingDate string `parquet:"filing_date"`
+  Name string `parquet:"name"`
+  Id string `parquet:"id"`
+  IdType string `parquet:"id_type"`
+  Weight float32 `parquet:"weight"`
+}
+
+// parquetExtendedHolding adds the columns of the nested objects, null when the component doesn't have them.
+type parquetExtendedHolding struct {
+  parquetHolding
+  DebtMaturityDate *string `parquet:"debt_maturity_date,optional"`
+  DebtCouponKind *string `parquet:"debt_coupon_kind,optional"`
+  DebtCouponRate *float32 `parquet:"debt_coupon_rate,optional"`
+  DebtIsDefault *bool `parquet:"debt_is_default,optional"`
+  DebtIsConvertible *bool `parquet:"debt_is_convertible,optional"`
+  LendingOnLoan *bool `parquet:"lending_on_loan,optional"`
+  LendingLoanValue *float64 `parquet:"lending_loan_value,optional"`
+  // Number of lots.
+  Lots *int32 `parquet:"lots,optional"`
+}
+
+// parquetFile writes the rows to a temporary file next to `path`, which replaces it when closed.
+type parquetFile struct {
+  path string
+  temp *os.File
+  writer *parquet.Writer
+}
+
+// createParquetFile creates the file `path` with the schema of `row` and row groups of at most `rowGroupRows` rows.
+func createParquetFile(path string, row any, rowGroupRows int64) (*parquetFile, error) {
+  temp, err := os.CreateTemp(filepath.Dir(path), "." + filepath.Base(path) + ".tmp-*")
+  if err != nil {
+    return nil, err
+  }
+  writer := parquet.NewWriter(temp, parquet.SchemaOf(row), parquet.MaxRowsPerRowGroup(rowGroupRows), parquet.PageBufferSize(kParquetPageBufferSize), parquet.CreatedBy("vanguard_etfs", "", ""))
+  return &parquetFile{path, temp, writer}, nil
+}
+
+func (f *parquetFile) write(row any) error {
+  return f.writer.Write(row)
+}
+
+// abort removes the temporary file, leaving `path` as it was.
+func (f *parquetFile) abort() {
+  f.temp.Close() // ignore error; the file is removed
+  os.Remove(f.temp.Name()) // ignore error; only a leftover temporary file
+}
+
+// close writes the remaining rows and the footer, then replaces `path`.
+func (f *parquetFile) close() error {
+  if err := f.writer.Close(); err != nil {
+    f.abort()
+    return err
+  }
+  if err := f.temp.Sync(); err != nil {
+    f.abort()
+    return err
+  }
+  if err := f.temp.Close(); err != nil {
+    os.Remove(f.temp.Name()) // ignore error; Close error takes precedence
+    return err
+  }
+  // CreateTemp uses 0600, we want the same permissions as the other outputs.
+  if err := os.Chmod(f.temp.Name(), 0644); err != nil {
+    os.Remove(f.temp.Name()) // ignore error; Chmod error takes precedence
+    return err
+  }
+  return renameSynced(f.temp.Name(), f.path)
+}
+
+// parquetHoldingWriter writes the holdings to `<dir>/<ETF>.parquet`, one file per ETF.
+// The rows of an ETF are expected to be written together.
+type parquetHoldingWriter struct {
+  dir string
+  // Adds the optional columns of the nested objects.
+  extended bool
+  rowGroupRows int64
+  etf string
+  // The file of `etf`, nil before the first row.
+  file *parquetFile
+}
+
+func newParquetHoldingWriter(dir string, extended bool) (*parquetHoldingWriter, error) {
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return nil, err
+  }
+  return &parquetHoldingWriter{dir, extended, kParquetRowGroupRows, "", nil}, nil
+}
+
+func (p *parquetHoldingWriter) closeFile() error {
+  if p.file == nil {
+    return nil
+  }
+  file := p.file
+  p.file = nil
+  if err := file.close(); err != nil {
+    return fmt.Errorf("writing %s: %w", file.path, err)
+  }
+  return nil
+}
+
+func (p *parquetHoldingWriter) writeHolding(etf string, index Index, component IndexComponent) error {
+  if etf != p.etf || p.file == nil {
+    if err := p.closeFile(); err != nil {
+      return err
+    }
+    var schema any = parquetHolding{}
+    if p.extended {
+      schema = parquetExtendedHolding{}
+    }
+    file, err := createParquetFile(filepath.Join(p.dir, etf + ".parquet"), schema, p.rowGroupRows)
+    if err != nil {
+      return err
+    }
+    p.etf = etf
+    p.file = file
+  }
+
+  holding := parquetHolding{etf, index.SeriesId, index.FilingDate, component.Name, component.Id, component.IdType, component.Weight}
+  var row any = holding
+  if p.extended {
+    extended := parquetExtendedHolding{parquetHolding: holding}
+    if d := component.Debt; d != nil {
+      convertible := d.Convertible != nil
+      extended.DebtMaturityDate, extended.DebtCouponKind, extended.DebtCouponRate = &d.MaturityDate, &d.CouponKind, &d.CouponRate
+      extended.DebtIsDefault, extended.DebtIsConvertible = &d.IsDefault, &convertible
+    }
+    if l := component.Lending; l != nil {
+      extended.LendingOnLoan, extended.LendingLoanValue = &l.OnLoan, &l.LoanValue
+    }
+    if component.Lots != nil {
+      lots := int32(len(component.Lots))
+      extended.Lots = &lots
+    }
+    row = extended
+  }
+  if err := p.file.write(row); err != nil {
+    p.file.abort()
+    p.file = nil
+    return fmt.Errorf("writing %s: %w", filepath.Join(p.dir, etf + ".parquet"), err)
+  }
+  return nil
+}
+
+func (p *parquetHoldingWriter) close() error {
+  return p.closeFile()
+}
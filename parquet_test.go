@@ -0,0 +1,146 @@
+package main
+
+import (
+  "io"
+  "os"
+  "path/filepath"
+  "reflect"
+  "testing"
+
+  "github.com/parquet-go/parquet-go"
+)
+
+// readParquetFile opens the Parquet file at `path` and reads all its rows.
+func readParquetFile[T any](t *testing.T, path string) (*parquet.File, []T) {
+  f, err := os.Open(path)
+  if err != nil {
+    t.Fatalf("Failed to open %s (err=%+v)", path, err)
+  }
+  t.Cleanup(func() { f.Close() })
+  info, err := f.Stat()
+  if err != nil {
+    t.Fatalf("Failed to stat %s (err=%+v)", path, err)
+  }
+  file, err := parquet.OpenFile(f, info.Size())
+  if err != nil {
+    t.Fatalf("Failed to read the Parquet metadata of %s (err=%+v)", path, err)
+  }
+  reader := parquet.NewGenericReader[T](f)
+  defer reader.Close()
+  rows := make([]T, file.NumRows())
+  if n, err := reader.Read(rows); int64(n) != file.NumRows() || (err != nil && err != io.EOF) {
+    t.Fatalf("Read %d rows of %d from %s (err=%+v)", n, file.NumRows(), path, err)
+  }
+  return file, rows
+}
+
+// parquetColumns returns the name of the columns of `file` and whether they are optional.
+func parquetColumns(file *parquet.File) map[string]bool {
+  columns := map[string]bool{}
+  for _, field := range file.Schema().Fields() {
+    columns[field.Name()] = field.Optional()
+  }
+  return columns
+}
+
+func TestExportParquet(t *testing.T) {
+  stock := IndexComponent{Name: "Apple Inc", Id: "US0378331005", IdType: "isin", Weight: 5}
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 1.5,
+    Debt: &DebtInfo{MaturityDate: "2050-02-15", CouponKind: "fixed", CouponRate: 2.5, Convertible: &ConvertibleInfo{}},
+    Lending: &LendingInfo{OnLoan: true, LoanValue: 1000.5},
+    Lots: []ComponentLot{ComponentLot{Line: 1, Name: "Lot 1", Weight: 1}, ComponentLot{Line: 2, Name: "Lot 2", Weight: 0.5}}}
+  indexes := []Index{
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{stock}},
+    Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{stock, bond}},
+  }
+
+  tt := []struct {
+    name string
+    extended bool
+    // Optional columns by name.
+    expectedColumns map[string]bool
+  } {
+    {"Default", false, map[string]bool{"etf": false, "series_id": false, "filing_date": false, "name": false, "id": false, "id_type": false, "weight": false}},
+    {"Extended", true, map[string]bool{"etf": false, "series_id": false, "filing_date": false, "name": false, "id": false, "id_type": false, "weight": false,
+      "debt_maturity_date": true, "debt_coupon_kind": true, "debt_coupon_rate": true, "debt_is_default": true, "debt_is_convertible": true,
+      "lending_on_loan": true, "lending_loan_value": true, "lots": true}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      dir := t.TempDir()
+      w, err := newParquetHoldingWriter(dir, tc.extended)
+      if err != nil {
+        t.Errorf("Failed to create the writer (err=%+v)", err)
+        return
+      }
+      if err := exportIndexes(w, "VXF", indexes, "", ""); err != nil {
+        t.Errorf("Failed to export (err=%+v)", err)
+        return
+      }
+      if err := exportIndexes(w, "VOO", indexes, "2025-01-02", ""); err != nil {
+        t.Errorf("Failed to export (err=%+v)", err)
+        return
+      }
+      if err := w.close(); err != nil {
+        t.Errorf("Failed to close the writer (err=%+v)", err)
+        return
+      }
+      if files := listDir(t, dir); !reflect.DeepEqual(files, []string{"VOO.parquet", "VXF.parquet"}) {
+        t.Errorf("Expected a file per ETF but got %v", files)
+        return
+      }
+
+      file, rows := readParquetFile[parquetExtendedHolding](t, filepath.Join(dir, "VXF.parquet"))
+      if columns := parquetColumns(file); !reflect.DeepEqual(columns, tc.expectedColumns) {
+        t.Errorf("Mismatched columns, expected=%v but got=%v", tc.expectedColumns, columns)
+        return
+      }
+      expected := []parquetExtendedHolding{
+        parquetExtendedHolding{parquetHolding: parquetHolding{"VXF", kValidSeriesId, "2025-02-01", "Apple Inc", "US0378331005", "isin", 5}},
+        parquetExtendedHolding{parquetHolding: parquetHolding{"VXF", kValidSeriesId, kDate, "Apple Inc", "US0378331005", "isin", 5}},
+        parquetExtendedHolding{parquetHolding: parquetHolding{"VXF", kValidSeriesId, kDate, "United States Treasury Strip Coupon", "US912834PZ59", "isin", 1.5}},
+      }
+      if tc.extended {
+        maturityDate, couponKind, couponRate, isDefault, isConvertible := "2050-02-15", "fixed", float32(2.5), false, true
+        onLoan, loanValue, lots := true, 1000.5, int32(2)
+        expected[2] = parquetExtendedHolding{expected[2].parquetHolding, &maturityDate, &couponKind, &couponRate, &isDefault, &isConvertible, &onLoan, &loanValue, &lots}
+      }
+      if !reflect.DeepEqual(rows, expected) {
+        t.Errorf("Mismatched rows, expected=%+v but got=%+v", expected, rows)
+        return
+      }
+
+      _, rows = readParquetFile[parquetExtendedHolding](t, filepath.Join(dir, "VOO.parquet"))
+      if len(rows) != 1 || rows[0].Etf != "VOO" || rows[0].FilingDate != "2025-02-01" {
+        t.Errorf("Expected the filing of 2025-02-01 for VOO but got %+v", rows)
+      }
+    })
+  }
+}
+
+func TestParquetRowGroups(t *testing.T) {
+  dir := t.TempDir()
+  w, err := newParquetHoldingWriter(dir, false)
+  if err != nil {
+    t.Errorf("Failed to create the writer (err=%+v)", err)
+    return
+  }
+  w.rowGroupRows = 2
+  index := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate}
+  for i := range 5 {
+    if err := w.writeHolding("VXF", index, IndexComponent{Name: "Company", Id: "US0378331005", IdType: "isin", Weight: float32(i)}); err != nil {
+      t.Errorf("Failed to write (err=%+v)", err)
+      return
+    }
+  }
+  if err := w.close(); err != nil {
+    t.Errorf("Failed to close the writer (err=%+v)", err)
+    return
+  }
+
+  file, rows := readParquetFile[parquetHolding](t, filepath.Join(dir, "VXF.parquet"))
+  if len(file.RowGroups()) != 3 || len(rows) != 5 || rows[4].Weight != 4 {
+    t.Errorf("Expected 5 rows in 3 row groups but got %d row groups and %+v", len(file.RowGroups()), rows)
+  }
+}
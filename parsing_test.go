@@ -16,16 +16,17 @@ func TestPopulate(t *testing.T) {
     invstOrSecXml string
     expected IndexComponent
   } {
-    {"Submission with `isin` and cusip", `<invstOrSec><name>Warby Parker Inc</name><cusip>93403J106</cusip><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.003502379516</pctVal></invstOrSec>`, IndexComponent{"Warby Parker Inc", "US93403J1060", "isin", 0.003502379516}},
-    {"Submission with other identifier (FAID)", `<invstOrSec><name>Daiichi Sankyo Co Ltd</name><cusip>N/A</cusip><identifiers><other otherDesc="FAID" value="023CVR996"/></identifiers><pctVal>0.000000000105</pctVal></invstOrSec>`, IndexComponent{"Daiichi Sankyo Co Ltd", "023CVR996", "faid", 0.000000000105}},
-    {"Submission with other identifier (SEDOL)", `<invstOrSec><name>Acer Inc</name><cusip>N/A</cusip><identifiers><other otherDesc="SEDOL" value="99X4570"/></identifiers><pctVal>0.000000000001</pctVal></invstOrSec>`, IndexComponent{"Acer Inc", "99X4570", "sedol", 0.000000000001}},
-    {"Submission with `ticker` identifier", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000001836174</pctVal></invstOrSec>`, IndexComponent{"Viridian Therapeutics Inc", "1843576D", "ticker", 0.000001836174}},
-    {"Submission with & in name", `<invstOrSec><name>Eli Lilly &amp; Co</name><cusip>532457108</cusip><identifiers><isin value="US5324571083"/></identifiers><pctVal>1.169779921999</pctVal></invstOrSec>`, IndexComponent{"Eli Lilly & Co", "US5324571083", "isin", 1.169779921999}},
+    {"Submission with `isin` and cusip", `<invstOrSec><name>Warby Parker Inc</name><cusip>93403J106</cusip><identifiers><isin value="US93403J1060"/></identifiers><pctVal>0.003502379516</pctVal></invstOrSec>`, IndexComponent{Name: "Warby Parker Inc", Id: "US93403J1060", IdType: "isin", Weight: 0.003502379516}},
+    {"Submission with other identifier (FAID)", `<invstOrSec><name>Daiichi Sankyo Co Ltd</name><cusip>N/A</cusip><identifiers><other otherDesc="FAID" value="023CVR996"/></identifiers><pctVal>0.000000000105</pctVal></invstOrSec>`, IndexComponent{Name: "Daiichi Sankyo Co Ltd", Id: "023CVR996", IdType: "faid", Weight: 0.000000000105}},
+    {"Submission with other identifier (SEDOL)", `<invstOrSec><name>Acer Inc</name><cusip>N/A</cusip><identifiers><other otherDesc="SEDOL" value="99X4570"/></identifiers><pctVal>0.000000000001</pctVal></invstOrSec>`, IndexComponent{Name: "Acer Inc", Id: "99X4570", IdType: "sedol", Weight: 0.000000000001}},
+    {"Submission with `ticker` identifier", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000001836174</pctVal></invstOrSec>`, IndexComponent{Name: "Viridian Therapeutics Inc", Id: "1843576D", IdType: "ticker", Weight: 0.000001836174}},
+    {"Submission with & in name", `<invstOrSec><name>Eli Lilly &amp; Co</name><cusip>532457108</cusip><identifiers><isin value="US5324571083"/></identifiers><pctVal>1.169779921999</pctVal></invstOrSec>`, IndexComponent{Name: "Eli Lilly & Co", Id: "US5324571083", IdType: "isin", Weight: 1.169779921999}},
+    {"Submission without identifier", `<invstOrSec><name>Pending Litigation</name><lei>N/A</lei><title>Contingent Value Rights</title><cusip>N/A</cusip><identifiers></identifiers><pctVal>0.000000000105</pctVal></invstOrSec>`, IndexComponent{Name: "Pending Litigation", Id: "SYNE27165CA73B63C28", IdType: "synthetic", Weight: 0.000000000105}},
 
     // Validates we don't underflow.
-    {"Submission with 0.000000558225 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000558225</pctVal></invstOrSec>`, IndexComponent{"Viridian Therapeutics Inc", "1843576D", "ticker", 0.000000558225}},
-    {"Submission with 0.000000000987 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000000987</pctVal></invstOrSec>`, IndexComponent{"Viridian Therapeutics Inc", "1843576D", "ticker", 0.000000000987}},
-    {"Submission with 0.000000000001 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000000001</pctVal></invstOrSec>`, IndexComponent{"Viridian Therapeutics Inc", "1843576D", "ticker", 0.000000000001}},
+    {"Submission with 0.000000558225 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000558225</pctVal></invstOrSec>`, IndexComponent{Name: "Viridian Therapeutics Inc", Id: "1843576D", IdType: "ticker", Weight: 0.000000558225}},
+    {"Submission with 0.000000000987 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000000987</pctVal></invstOrSec>`, IndexComponent{Name: "Viridian Therapeutics Inc", Id: "1843576D", IdType: "ticker", Weight: 0.000000000987}},
+    {"Submission with 0.000000000001 weight", `<invstOrSec><name>Viridian Therapeutics Inc</name><cusip>901535101</cusip><identifiers><ticker value="1843576D"/></identifiers><pctVal>0.000000000001</pctVal></invstOrSec>`, IndexComponent{Name: "Viridian Therapeutics Inc", Id: "1843576D", IdType: "ticker", Weight: 0.000000000001}},
   }
 
   for _, tc := range tt {
@@ -49,6 +50,10 @@ func TestPopulate(t *testing.T) {
         t.Errorf("Invalid filingDate, got=%s (submission=%+v)", index.FilingDate, submission)
         return
       }
+      if index.AccessionNumber != kAccessionNumber {
+        t.Errorf("Invalid accessionNumber, got=%s (submission=%+v)", index.AccessionNumber, submission)
+        return
+      }
 
       if len(index.Components) != 1 {
         t.Errorf("Expect 1 components but got %d (full_payload=%+v)", len(index.Components), index)
@@ -0,0 +1,127 @@
+package main
+
+import (
+  "errors"
+  "io/fs"
+  "slices"
+  "strings"
+  "time"
+)
+
+// Subset of the `<returnInfo>` block of the N-PORT specification.
+// Each block covers the 3 months of the reporting period, the 3rd month
+// being the month of the reporting period's date (`<repPdDate>`).
+type returnInfo struct {
+  MonthlyTotReturns struct {
+    MonthlyTotReturn []struct {
+      ClassId string `xml:"classId,attr"`
+      Rtn1 string `xml:"rtn1,attr"`
+      Rtn2 string `xml:"rtn2,attr"`
+      Rtn3 string `xml:"rtn3,attr"`
+    } `xml:"monthlyTotReturn"`
+  } `xml:"monthlyTotReturns"`
+  // Gains attributable to derivatives, per asset category.
+  MonthlyReturnCats struct {
+    MonthlyReturnCat []struct {
+      ContractType string `xml:"contractType,attr"`
+      Mon1 monthlyGains `xml:"mon1"`
+      Mon2 monthlyGains `xml:"mon2"`
+      Mon3 monthlyGains `xml:"mon3"`
+    } `xml:"monthlyReturnCat"`
+  } `xml:"monthlyReturnCats"`
+  // Gains attributable to anything but derivatives.
+  OthMon1 monthlyGains `xml:"othMon1"`
+  OthMon2 monthlyGains `xml:"othMon2"`
+  OthMon3 monthlyGains `xml:"othMon3"`
+}
+
+type monthlyGains struct {
+  NetRealizedGain string `xml:"netRealizedGain,attr"`
+  NetUnrealizedAppr string `xml:"netUnrealizedAppr,attr"`
+}
+
+type monthlyFlow struct {
+  Sales string `xml:"sales,attr"`
+  Reinvestment string `xml:"reinvestment,attr"`
+  Redemption string `xml:"redemption,attr"`
+}
+
+// Category used for the gains that are not attributable to derivatives.
+const kNonDerivativeCategory = "non_derivative"
+
+func toGains(g monthlyGains) Gains {
+  return Gains{NetRealizedGain: parseFloat64(g.NetRealizedGain), NetUnrealizedAppreciation: parseFloat64(g.NetUnrealizedAppr)}
+}
+
+func toFlows(f monthlyFlow) Flows {
+  return Flows{Sales: parseFloat64(f.Sales), Reinvestments: parseFloat64(f.Reinvestment), Redemptions: parseFloat64(f.Redemption)}
+}
+
+// populatePerformanceFromSingleSubmission returns the 3 months covered by the submission,
+// ordered from the newest to the oldest. It returns no month if the reporting period is unknown.
+func populatePerformanceFromSingleSubmission(submission singleSubmission, info SubmissionInfo) []MonthlyPerformance {
+  periodDate, err := time.Parse(time.DateOnly, submission.FormData.GenInfo.RepPdDate)
+  if err != nil {
+    return []MonthlyPerformance{}
+  }
+  fundInfo := submission.FormData.FundInfo
+
+  months := []MonthlyPerformance{}
+  for i := range 3 {
+    // Use the first day of the month to avoid overflowing into the next month (e.g. Mar 31 - 1 month).
+    month := time.Date(periodDate.Year(), periodDate.Month() - time.Month(2 - i), 1, 0, 0, 0, 0, time.UTC)
+    months = append(months, MonthlyPerformance{Month: month.Format("2006-01"), FilingDate: info.FilingDate, TotalReturns: map[string]float32{}, Gains: map[string]Gains{}, Flows: Flows{}})
+  }
+
+  for _, ret := range fundInfo.ReturnInfo.MonthlyTotReturns.MonthlyTotReturn {
+    for i, rtn := range []string{ret.Rtn1, ret.Rtn2, ret.Rtn3} {
+      if rtn == "" || rtn == "N/A" {
+        continue
+      }
+      months[i].TotalReturns[ret.ClassId] = parseFloat32(rtn)
+    }
+  }
+  for _, cat := range fundInfo.ReturnInfo.MonthlyReturnCats.MonthlyReturnCat {
+    for i, gains := range []monthlyGains{cat.Mon1, cat.Mon2, cat.Mon3} {
+      months[i].Gains[cat.ContractType] = toGains(gains)
+    }
+  }
+  for i, gains := range []monthlyGains{fundInfo.ReturnInfo.OthMon1, fundInfo.ReturnInfo.OthMon2, fundInfo.ReturnInfo.OthMon3} {
+    months[i].Gains[kNonDerivativeCategory] = toGains(gains)
+  }
+  for i, flow := range []monthlyFlow{fundInfo.Mon1Flow, fundInfo.Mon2Flow, fundInfo.Mon3Flow} {
+    months[i].Flows = toFlows(flow)
+  }
+
+  slices.Reverse(months)
+  return months
+}
+
+// mergePerformance adds `months` to `existing`, replacing the months that are already present.
+// The result is ordered from the newest to the oldest month.
+func mergePerformance(existing []MonthlyPerformance, months []MonthlyPerformance) []MonthlyPerformance {
+  res := slices.Clone(existing)
+  for _, month := range months {
+    idx := slices.IndexFunc(res, func (p MonthlyPerformance) bool { return p.Month == month.Month })
+    if idx == -1 {
+      res = append(res, month)
+    } else {
+      res[idx] = month
+    }
+  }
+  slices.SortFunc(res, func (a, b MonthlyPerformance) int {
+    // a and b are flipped to get the newest to oldest behavior.
+    return strings.Compare(b.Month, a.Month)
+  })
+  return res
+}
+
+// readPerformance returns the stored performance for `etf`. A missing file is not an error
+// as the dataset is only populated for the filings fetched after its introduction.
+func readPerformance(etf string) ([]MonthlyPerformance, error) {
+  v, err := storage.ReadPerformance(etf)
+  if errors.Is(err, fs.ErrNotExist) {
+    return []MonthlyPerformance{}, nil
+  }
+  return v, err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "testing"
+)
+
+const kFundInfoXml = `<fundInfo>
+<netAssets>1000000</netAssets>
+<returnInfo>
+<monthlyTotReturns>
+<monthlyTotReturn classId="C000007800" rtn1="1.5" rtn2="-0.25" rtn3="2"/>
+<monthlyTotReturn classId="C000007801" rtn1="1.4" rtn2="N/A" rtn3="1.9"/>
+</monthlyTotReturns>
+<monthlyReturnCats>
+<monthlyReturnCat contractType="EQ">
+<mon1 netRealizedGain="10" netUnrealizedAppr="-5"/>
+<mon2 netRealizedGain="20" netUnrealizedAppr="-6"/>
+<mon3 netRealizedGain="30" netUnrealizedAppr="-7"/>
+</monthlyReturnCat>
+</monthlyReturnCats>
+<othMon1 netRealizedGain="100" netUnrealizedAppr="1000"/>
+<othMon2 netRealizedGain="200" netUnrealizedAppr="2000"/>
+<othMon3 netRealizedGain="300" netUnrealizedAppr="3000"/>
+</returnInfo>
+<mon1Flow sales="1" reinvestment="2" redemption="3"/>
+<mon2Flow sales="4" reinvestment="5" redemption="6"/>
+<mon3Flow sales="7" reinvestment="8" redemption="9"/>
+</fundInfo>`
+
+func TestPopulatePerformance(t *testing.T) {
+  tt := []struct {
+    name string
+    repPdDate string
+    expectedMonths []string
+  } {
+    {"Quarter ending in March", "2025-03-31", []string{"2025-03", "2025-02", "2025-01"}},
+    {"Quarter spanning 2 years", "2025-01-31", []string{"2025-01", "2024-12", "2024-11"}},
+    {"Unknown reporting period", "N/A", []string{}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      payload := fmt.Sprintf(`<edgarSubmission><formData><genInfo><seriesName>VANGUARD TOTAL STOCK MARKET INDEX FUND</seriesName><seriesId>S000002848</seriesId><repPdDate>%s</repPdDate></genInfo>%s</formData></edgarSubmission>`, tc.repPdDate, kFundInfoXml)
+      submission := singleSubmission{}
+      err := xml.Unmarshal([]byte(payload), &submission)
+      if err != nil {
+        panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", payload, err))
+      }
+      months := populatePerformanceFromSingleSubmission(submission, SubmissionInfo{kCik, kAccessionNumber, kSubmissionDate})
+      if len(months) != len(tc.expectedMonths) {
+        t.Errorf("Expected %d months but got %d (months=%+v)", len(tc.expectedMonths), len(months), months)
+        return
+      }
+      for i, month := range months {
+        if month.Month != tc.expectedMonths[i] {
+          t.Errorf("Mismatched month at %d, expected=%s but got=%s", i, tc.expectedMonths[i], month.Month)
+          return
+        }
+        if month.FilingDate != kSubmissionDate {
+          t.Errorf("Mismatched filing date at %d, got=%s", i, month.FilingDate)
+          return
+        }
+      }
+      if len(months) == 0 {
+        return
+      }
+
+      // The newest month is the 3rd month of the reporting period.
+      newest := months[0]
+      if newest.TotalReturns["C000007800"] != 2 || newest.TotalReturns["C000007801"] != 1.9 {
+        t.Errorf("Mismatched total returns, got=%+v", newest.TotalReturns)
+      }
+      if newest.Gains["EQ"] != (Gains{NetRealizedGain: 30, NetUnrealizedAppreciation: -7}) {
+        t.Errorf("Mismatched EQ gains, got=%+v", newest.Gains["EQ"])
+      }
+      if newest.Gains[kNonDerivativeCategory] != (Gains{NetRealizedGain: 300, NetUnrealizedAppreciation: 3000}) {
+        t.Errorf("Mismatched non-derivative gains, got=%+v", newest.Gains[kNonDerivativeCategory])
+      }
+      if newest.Flows != (Flows{Sales: 7, Reinvestments: 8, Redemptions: 9}) {
+        t.Errorf("Mismatched flows, got=%+v", newest.Flows)
+      }
+      // "N/A" returns are skipped.
+      if _, ok := months[1].TotalReturns["C000007801"]; ok {
+        t.Errorf("Expected no return for C000007801 but got %+v", months[1].TotalReturns)
+      }
+      if months[2].Flows != (Flows{Sales: 1, Reinvestments: 2, Redemptions: 3}) {
+        t.Errorf("Mismatched flows for the oldest month, got=%+v", months[2].Flows)
+      }
+    })
+  }
+}
+
+func TestMergePerformance(t *testing.T) {
+  month := func (month, filingDate string) MonthlyPerformance {
+    return MonthlyPerformance{Month: month, FilingDate: filingDate, TotalReturns: map[string]float32{}, Gains: map[string]Gains{}, Flows: Flows{}}
+  }
+  tt := []struct {
+    name string
+    existing []MonthlyPerformance
+    months []MonthlyPerformance
+    expected []MonthlyPerformance
+  } {
+    {"Empty", []MonthlyPerformance{}, []MonthlyPerformance{month("2025-03", "2025-05-28")}, []MonthlyPerformance{month("2025-03", "2025-05-28")}},
+    {"Newer months are added first", []MonthlyPerformance{month("2024-12", "2025-02-27")}, []MonthlyPerformance{month("2025-03", "2025-05-28"), month("2025-02", "2025-05-28")}, []MonthlyPerformance{month("2025-03", "2025-05-28"), month("2025-02", "2025-05-28"), month("2024-12", "2025-02-27")}},
+    {"Older months are added last", []MonthlyPerformance{month("2025-03", "2025-05-28")}, []MonthlyPerformance{month("2024-12", "2025-02-27")}, []MonthlyPerformance{month("2025-03", "2025-05-28"), month("2024-12", "2025-02-27")}},
+    {"Existing months are replaced", []MonthlyPerformance{month("2025-03", "2025-05-28")}, []MonthlyPerformance{month("2025-03", "2025-06-01")}, []MonthlyPerformance{month("2025-03", "2025-06-01")}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      res := mergePerformance(tc.existing, tc.months)
+      if len(res) != len(tc.expected) {
+        t.Errorf("Mismatch in length, expected=%+v but got=%+v", tc.expected, res)
+        return
+      }
+      for i := range res {
+        if res[i].Month != tc.expected[i].Month || res[i].FilingDate != tc.expected[i].FilingDate {
+          t.Errorf("Mismatch at %d (expected=%+v vs got=%+v)", i, tc.expected[i], res[i])
+          return
+        }
+      }
+    })
+  }
+}
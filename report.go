@@ -0,0 +1,120 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "os"
+)
+
+// Exit codes of the process, reflecting the worst severity of the validation results.
+// Other non-zero codes (1 for failures, 2 for panics) mean that the run didn't complete.
+const kExitCodeWarnings = 3
+const kExitCodeErrors = 4
+
+func exitCode(severity Severity) int {
+  switch severity {
+    case kSeverityWarning:
+      return kExitCodeWarnings
+    case kSeverityError:
+      return kExitCodeErrors
+  }
+  return 0
+}
+
+// validationIssuesError is returned by the commands whose exit code reflects the worst severity of the
+// validation issues, after reporting them.
+type validationIssuesError struct {
+  severity Severity
+}
+
+func (e validationIssuesError) Error() string {
+  return fmt.Sprintf("the validation found issues of severity %s", e.severity)
+}
+
+type jsonValidationResult struct {
+  Etf string `json:"etf"`
+  Cik int `json:"cik"`
+  SeriesId string `json:"series_id"`
+  FilingDate string `json:"filing_date"`
+  Severity string `json:"severity"`
+  Errors []string `json:"errors"`
+  Warnings []string `json:"warnings"`
+}
+
+type jsonValidationReport struct {
+  WorstSeverity string `json:"worst_severity"`
+  Results []jsonValidationResult `json:"results"`
+}
+
+func writeJsonReport(path string, s *RunSummary) error {
+  report := jsonValidationReport{s.worstSeverity().String(), []jsonValidationResult{}}
+  for _, res := range s.results {
+    report.Results = append(report.Results, jsonValidationResult{res.etfName, res.cik, res.seriesId, res.filingDate, res.severity().String(), res.errors, res.warnings})
+  }
+  return writeToJsonFile(path, report)
+}
+
+// JUnit XML format, as understood by most CI systems.
+// Each validated filing is a test case: errors are failures and warnings are reported as output.
+type junitFailure struct {
+  Message string `xml:"message,attr"`
+  Text string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+  Name string `xml:"name,attr"`
+  ClassName string `xml:"classname,attr"`
+  Failures []junitFailure `xml:"failure"`
+  SystemOut string `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+  XMLName xml.Name `xml:"testsuite"`
+  Name string `xml:"name,attr"`
+  Tests int `xml:"tests,attr"`
+  Failures int `xml:"failures,attr"`
+  TestCases []junitTestCase `xml:"testcase"`
+}
+
+func writeJunitReport(path string, s *RunSummary) error {
+  suite := junitTestSuite{Name: "validation", TestCases: []junitTestCase{}}
+  for _, res := range s.results {
+    etfName := res.etfName
+    if etfName == "" {
+      etfName = res.seriesId
+    }
+    testCase := junitTestCase{Name: fmt.Sprintf("%s %s", etfName, res.filingDate), ClassName: fmt.Sprintf("cik%d", res.cik)}
+    for _, err := range res.errors {
+      testCase.Failures = append(testCase.Failures, junitFailure{err, err})
+    }
+    for _, warning := range res.warnings {
+      testCase.SystemOut += fmt.Sprintf("warning: %s\n", warning)
+    }
+    suite.Tests++
+    if len(res.errors) > 0 {
+      suite.Failures++
+    }
+    suite.TestCases = append(suite.TestCases, testCase)
+  }
+
+  bytes, err := xml.MarshalIndent(suite, "", "  ")
+  if err != nil {
+    return err
+  }
+  return os.WriteFile(path, append([]byte(xml.Header), bytes...), 0644)
+}
+
+// writeReports writes the reports whose path is not empty.
+func writeReports(s *RunSummary, jsonPath, junitPath string) error {
+  if jsonPath != "" {
+    if err := writeJsonReport(jsonPath, s); err != nil {
+      return err
+    }
+  }
+  if junitPath != "" {
+    if err := writeJunitReport(junitPath, s); err != nil {
+      return err
+    }
+  }
+  return nil
+}
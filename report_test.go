@@ -0,0 +1,93 @@
+package main
+
+import (
+  "encoding/xml"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func newSummary(results ...ValidationResult) *RunSummary {
+  summary := &RunSummary{}
+  for _, res := range results {
+    summary.add(res)
+  }
+  return summary
+}
+
+func TestWorstSeverity(t *testing.T) {
+  clean := ValidationResult{"VXF", kCompanyId, kValidSeriesId, kDate, []string{}, []string{}, []IndexComponent{}}
+  warning := ValidationResult{"VXF", kCompanyId, kValidSeriesId, kDate, []string{"warning"}, []string{}, []IndexComponent{}}
+  withErrors := ValidationResult{"VXF", kCompanyId, kValidSeriesId, kDate, []string{}, []string{"error"}, []IndexComponent{}}
+  tt := []struct {
+    name string
+    summary *RunSummary
+    expected Severity
+    expectedExitCode int
+  } {
+    {"Empty run", newSummary(), kSeverityNone, 0},
+    {"Clean run", newSummary(clean, clean), kSeverityNone, 0},
+    {"Warnings", newSummary(clean, warning), kSeverityWarning, kExitCodeWarnings},
+    {"Errors win over warnings", newSummary(warning, withErrors, clean), kSeverityError, kExitCodeErrors},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      severity := tc.summary.worstSeverity()
+      if severity != tc.expected {
+        t.Errorf("Mismatched severity, expected=%s but got=%s", tc.expected, severity)
+        return
+      }
+      if exitCode(severity) != tc.expectedExitCode {
+        t.Errorf("Mismatched exit code, expected=%d but got=%d", tc.expectedExitCode, exitCode(severity))
+        return
+      }
+    })
+  }
+}
+
+func TestReports(t *testing.T) {
+  summary := newSummary(
+    ValidationResult{"VXF", kCompanyId, kValidSeriesId, kDate, []string{"warning"}, []string{}, []IndexComponent{}},
+    ValidationResult{"VXF", kCompanyId, kValidSeriesId, "2025-02-01", []string{}, []string{"error 1", "error 2"}, []IndexComponent{}})
+  dir := t.TempDir()
+  jsonPath := filepath.Join(dir, "report.json")
+  junitPath := filepath.Join(dir, "report.xml")
+  if err := writeReports(summary, jsonPath, junitPath); err != nil {
+    t.Errorf("Failed to write the reports (err=%+v)", err)
+    return
+  }
+
+  jsonReport := jsonValidationReport{}
+  if err := readJsonFile(jsonPath, &jsonReport); err != nil {
+    t.Errorf("Failed to read the JSON report (err=%+v)", err)
+    return
+  }
+  if jsonReport.WorstSeverity != "error" || len(jsonReport.Results) != 2 {
+    t.Errorf("Unexpected JSON report %+v", jsonReport)
+    return
+  }
+  if jsonReport.Results[0].Severity != "warning" || jsonReport.Results[1].Severity != "error" {
+    t.Errorf("Unexpected severities in JSON report %+v", jsonReport)
+    return
+  }
+
+  bytes, err := os.ReadFile(junitPath)
+  if err != nil {
+    t.Errorf("Failed to read the JUnit report (err=%+v)", err)
+    return
+  }
+  suite := junitTestSuite{}
+  if err := xml.Unmarshal(bytes, &suite); err != nil {
+    t.Errorf("Failed to parse the JUnit report (err=%+v)", err)
+    return
+  }
+  if suite.Tests != 2 || suite.Failures != 1 {
+    t.Errorf("Unexpected JUnit counts tests=%d, failures=%d", suite.Tests, suite.Failures)
+    return
+  }
+  if len(suite.TestCases[1].Failures) != 2 || suite.TestCases[0].SystemOut != "warning: warning\n" {
+    t.Errorf("Unexpected JUnit test cases %+v", suite.TestCases)
+    return
+  }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+)
+
+// Risk metrics are reported per maturity bucket, in USD.
+type maturityPeriods struct {
+  Period3Mon string `xml:"period3Mon,attr"`
+  Period1Yr string `xml:"period1Yr,attr"`
+  Period5Yr string `xml:"period5Yr,attr"`
+  Period10Yr string `xml:"period10Yr,attr"`
+  Period30Yr string `xml:"period30Yr,attr"`
+}
+
+// Subset of the `<fundInfo>` risk metrics of the N-PORT specification.
+// They are only reported by funds with at least 25% of their net assets in debt securities.
+type riskInfo struct {
+  CurMetrics struct {
+    CurMetric []struct {
+      CurCd string `xml:"curCd"`
+      IntrstRtRiskdv01 maturityPeriods `xml:"intrstRtRiskdv01"`
+      IntrstRtRiskdv100 maturityPeriods `xml:"intrstRtRiskdv100"`
+    } `xml:"curMetric"`
+  } `xml:"curMetrics"`
+  CreditSprdRiskInvstGrade maturityPeriods `xml:"creditSprdRiskInvstGrade"`
+  CreditSprdRiskNonInvstGrade maturityPeriods `xml:"creditSprdRiskNonInvstGrade"`
+}
+
+func toMaturityRisk(p maturityPeriods) MaturityRisk {
+  return MaturityRisk{ThreeMonths: parseFloat64(p.Period3Mon), OneYear: parseFloat64(p.Period1Yr), FiveYears: parseFloat64(p.Period5Yr), TenYears: parseFloat64(p.Period10Yr), ThirtyYears: parseFloat64(p.Period30Yr)}
+}
+
+// getRiskMetrics returns nil if the fund doesn't report any risk metric.
+func getRiskMetrics(r riskInfo) *RiskMetrics {
+  if len(r.CurMetrics.CurMetric) == 0 && r.CreditSprdRiskInvstGrade == (maturityPeriods{}) && r.CreditSprdRiskNonInvstGrade == (maturityPeriods{}) {
+    return nil
+  }
+  metrics := RiskMetrics{InterestRate: []CurrencyRisk{}, CreditSpreadInvestmentGrade: toMaturityRisk(r.CreditSprdRiskInvstGrade), CreditSpreadNonInvestmentGrade: toMaturityRisk(r.CreditSprdRiskNonInvstGrade)}
+  for _, metric := range r.CurMetrics.CurMetric {
+    metrics.InterestRate = append(metrics.InterestRate, CurrencyRisk{Currency: metric.CurCd, Dv01: toMaturityRisk(metric.IntrstRtRiskdv01), Dv100: toMaturityRisk(metric.IntrstRtRiskdv100)})
+  }
+  return &metrics
+}
+
+func printRiskRow(filingDate, metric, currency string, r MaturityRisk) {
+  fmt.Printf("%-11s %-14s %-8s %16.2f %16.2f %16.2f %16.2f %16.2f\n", filingDate, metric, currency, r.ThreeMonths, r.OneYear, r.FiveYears, r.TenYears, r.ThirtyYears)
+}
+
+func runRiskReport(args []string) error {
+  flags := flag.NewFlagSet("risk", flag.ExitOnError)
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to report on. Defaults to all ETFs with risk metrics")
+  flags.Parse(args)
+
+  for _, etf := range selectEtfs(*etfsFlag) {
+    indexes, err := readAllIndexes(etf)
+    if err != nil {
+      fmt.Printf("Skipping %s as its file couldn't be read (err=%+v)\n", etf, err)
+      continue
+    }
+    hasRisk := false
+    for _, index := range indexes {
+      if index.Risk == nil {
+        continue
+      }
+      if !hasRisk {
+        hasRisk = true
+        fmt.Printf("***************** Risk metrics for %s *****************\n", etf)
+        fmt.Printf("%-11s %-14s %-8s %16s %16s %16s %16s %16s\n", "Filing date", "Metric", "Currency", "3 months", "1 year", "5 years", "10 years", "30 years")
+      }
+      for _, currencyRisk := range index.Risk.InterestRate {
+        printRiskRow(index.FilingDate, "DV01", currencyRisk.Currency, currencyRisk.Dv01)
+        printRiskRow(index.FilingDate, "DV100", currencyRisk.Currency, currencyRisk.Dv100)
+      }
+      printRiskRow(index.FilingDate, "Spread IG", "", index.Risk.CreditSpreadInvestmentGrade)
+      printRiskRow(index.FilingDate, "Spread non-IG", "", index.Risk.CreditSpreadNonInvestmentGrade)
+    }
+    if hasRisk {
+      fmt.Printf("\n")
+    }
+  }
+  return nil
+}
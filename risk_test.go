@@ -0,0 +1,52 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "testing"
+)
+
+func TestRiskMetrics(t *testing.T) {
+  tt := []struct {
+    name string
+    fundInfoXml string
+    // nil if no risk metrics are expected.
+    expected *RiskMetrics
+  } {
+    {"Equity fund", `<fundInfo><netAssets>1000</netAssets></fundInfo>`, nil},
+    {"Bond fund", `<fundInfo><netAssets>1000</netAssets>
+<curMetrics>
+<curMetric><curCd>USD</curCd><intrstRtRiskdv01 period3Mon="-1.5" period1Yr="-2" period5Yr="-3" period10Yr="-4" period30Yr="-5"/><intrstRtRiskdv100 period3Mon="-150" period1Yr="-200" period5Yr="-300" period10Yr="-400" period30Yr="-500"/></curMetric>
+<curMetric><curCd>EUR</curCd><intrstRtRiskdv01 period3Mon="0" period1Yr="0" period5Yr="-1" period10Yr="0" period30Yr="0"/><intrstRtRiskdv100 period3Mon="0" period1Yr="0" period5Yr="-100" period10Yr="0" period30Yr="0"/></curMetric>
+</curMetrics>
+<creditSprdRiskInvstGrade period3Mon="-0.1" period1Yr="-0.2" period5Yr="-0.3" period10Yr="-0.4" period30Yr="-0.5"/>
+<creditSprdRiskNonInvstGrade period3Mon="0" period1Yr="0" period5Yr="0" period10Yr="0" period30Yr="N/A"/>
+</fundInfo>`, &RiskMetrics{InterestRate: []CurrencyRisk{CurrencyRisk{Currency: "USD", Dv01: MaturityRisk{ThreeMonths: -1.5, OneYear: -2, FiveYears: -3, TenYears: -4, ThirtyYears: -5}, Dv100: MaturityRisk{ThreeMonths: -150, OneYear: -200, FiveYears: -300, TenYears: -400, ThirtyYears: -500}}, CurrencyRisk{Currency: "EUR", Dv01: MaturityRisk{ThreeMonths: 0, OneYear: 0, FiveYears: -1, TenYears: 0, ThirtyYears: 0}, Dv100: MaturityRisk{ThreeMonths: 0, OneYear: 0, FiveYears: -100, TenYears: 0, ThirtyYears: 0}}}, CreditSpreadInvestmentGrade: MaturityRisk{ThreeMonths: -0.1, OneYear: -0.2, FiveYears: -0.3, TenYears: -0.4, ThirtyYears: -0.5}, CreditSpreadNonInvestmentGrade: MaturityRisk{ThreeMonths: 0, OneYear: 0, FiveYears: 0, TenYears: 0, ThirtyYears: 0}}},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      payload := fmt.Sprintf(`<edgarSubmission><formData><genInfo><seriesName>VANGUARD EXTENDED DURATION TREASURY INDEX FUND</seriesName><seriesId>S000004453</seriesId></genInfo>%s</formData></edgarSubmission>`, tc.fundInfoXml)
+      submission := singleSubmission{}
+      err := xml.Unmarshal([]byte(payload), &submission)
+      if err != nil {
+        panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", payload, err))
+      }
+      index := populateIndexFromSingleSubmission(submission, SubmissionInfo{kCik, kAccessionNumber, kSubmissionDate})
+      if tc.expected == nil {
+        if index.Risk != nil {
+          t.Errorf("Expected no risk metrics but got %+v", index.Risk)
+        }
+        return
+      }
+      if index.Risk == nil {
+        t.Errorf("Expected risk metrics %+v but got none", tc.expected)
+        return
+      }
+      if fmt.Sprintf("%+v", *index.Risk) != fmt.Sprintf("%+v", *tc.expected) {
+        t.Errorf("Mismatched risk metrics, expected=%+v but got=%+v", tc.expected, index.Risk)
+        return
+      }
+    })
+  }
+}
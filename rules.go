@@ -0,0 +1,396 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "io/fs"
+  "maps"
+  "math"
+  "slices"
+)
+
+// Optional configuration of the validation rules.
+const kValidationConfigFile = "./validation_config.json"
+
+// RuleConfig configures a validation rule. Unset fields keep their default value.
+type RuleConfig struct {
+  // One of "off", "warning" or "error".
+  Severity string `json:"severity,omitempty"`
+  // Rule-specific parameters, see kValidationRules.
+  Min *float64 `json:"min,omitempty"`
+  Max *float64 `json:"max,omitempty"`
+  Values []string `json:"values,omitempty"`
+}
+
+// merge returns `c` with the fields set in `override` replaced.
+func (c RuleConfig) merge(override RuleConfig) RuleConfig {
+  if override.Severity != "" {
+    c.Severity = override.Severity
+  }
+  if override.Min != nil {
+    c.Min = override.Min
+  }
+  if override.Max != nil {
+    c.Max = override.Max
+  }
+  if override.Values != nil {
+    c.Values = override.Values
+  }
+  return c
+}
+
+type ValidationConfig struct {
+  // By default, we stop validating an index when its series has issues (e.g. it's not one of our ETFs).
+  ContinueOnSeriesIssues bool `json:"continue_on_series_issues"`
+  // Keyed by rule name.
+  Rules map[string]RuleConfig `json:"rules"`
+  // Per-ETF overrides, applied on top of `Rules`. Keyed by ETF, then by rule name.
+  Etfs map[string]map[string]RuleConfig `json:"etfs"`
+}
+
+var validationConfig = ValidationConfig{false, map[string]RuleConfig{}, map[string]map[string]RuleConfig{}}
+
+func (c ValidationConfig) ruleConfig(rule validationRule, etf string) RuleConfig {
+  cfg := rule.defaults.merge(c.Rules[rule.name])
+  return cfg.merge(c.Etfs[etf][rule.name])
+}
+
+func parseSeverity(s string) (Severity, error) {
+  switch s {
+    case "off":
+      return kSeverityNone, nil
+    case "warning":
+      return kSeverityWarning, nil
+    case "error":
+      return kSeverityError, nil
+  }
+  return kSeverityNone, fmt.Errorf("unknown severity %q, expected one of off, warning or error", s)
+}
+
+type paramKind int
+const (
+  // Non-negative amounts, e.g. percentage points.
+  kAmountParam paramKind = iota
+  // Fractions, in [0, 1].
+  kFractionParam
+  // Non-negative whole numbers.
+  kCountParam
+)
+
+// Kind of the `min` and `max` parameters of the rules, kAmountParam if not listed.
+var kRuleParamKinds = map[string]paramKind{
+  "top_holding_disappeared": kCountParam,
+  "id_type_mix_change": kFractionParam,
+}
+
+// checkRuleParams checks the parameters of `cfg`, the configuration of `rule` merged with its defaults.
+func checkRuleParams(rule validationRule, cfg RuleConfig) error {
+  if cfg.Values != nil && rule.defaults.Values == nil {
+    return fmt.Errorf("the rule doesn't take values")
+  }
+  params := []struct {
+    name string
+    value *float64
+    used bool
+  } {
+    {"min", cfg.Min, rule.defaults.Min != nil},
+    {"max", cfg.Max, rule.defaults.Max != nil},
+  }
+  for _, p := range params {
+    if p.value == nil {
+      continue
+    }
+    if !p.used {
+      return fmt.Errorf("the rule doesn't take %s", p.name)
+    }
+    v := *p.value
+    switch kind := kRuleParamKinds[rule.name]; {
+      case v < 0 || math.IsNaN(v):
+        return fmt.Errorf("%s=%v is negative", p.name, v)
+      case kind == kFractionParam && v > 1:
+        return fmt.Errorf("%s=%v is a fraction, it must be in [0, 1]", p.name, v)
+      case kind == kCountParam && v != math.Trunc(v):
+        return fmt.Errorf("%s=%v is a count, it must be a whole number", p.name, v)
+    }
+  }
+  if cfg.Min != nil && cfg.Max != nil && *cfg.Min > *cfg.Max {
+    return fmt.Errorf("min=%v is greater than max=%v", *cfg.Min, *cfg.Max)
+  }
+  return nil
+}
+
+// checkRuleConfigs checks `configs`, which override `base` (nil for the global configuration).
+func checkRuleConfigs(configs map[string]RuleConfig, base map[string]RuleConfig) error {
+  for name, cfg := range configs {
+    i := slices.IndexFunc(kValidationRules, func (rule validationRule) bool { return rule.name == name })
+    if i == -1 {
+      return fmt.Errorf("unknown rule %q", name)
+    }
+    if cfg.Severity != "" {
+      if _, err := parseSeverity(cfg.Severity); err != nil {
+        return fmt.Errorf("rule %q: %w", name, err)
+      }
+    }
+    rule := kValidationRules[i]
+    if err := checkRuleParams(rule, rule.defaults.merge(base[name]).merge(cfg)); err != nil {
+      return fmt.Errorf("rule %q: %w", name, err)
+    }
+  }
+  return nil
+}
+
+// loadValidationConfig loads the configuration at `path`. A missing file keeps the defaults.
+// The rules and their severities and parameters are checked, so that a bad configuration doesn't fail the runs.
+func loadValidationConfig(path string) error {
+  config := ValidationConfig{false, map[string]RuleConfig{}, map[string]map[string]RuleConfig{}}
+  err := readJsonFile(path, &config)
+  if errors.Is(err, fs.ErrNotExist) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+
+  if err := checkRuleConfigs(config.Rules, nil); err != nil {
+    return err
+  }
+  for etf, configs := range config.Etfs {
+    if err := checkRuleConfigs(configs, config.Rules); err != nil {
+      return fmt.Errorf("ETF %s: %w", etf, err)
+    }
+  }
+  validationConfig = config
+  return nil
+}
+
+type ruleStage int
+const (
+  // Rules about the series itself, which identify the ETF.
+  kSeriesStage ruleStage = iota
+  // Rules about the index and its components.
+  kIndexStage
+  // Rules comparing the index with the previous filing.
+  kCrossFilingStage
+)
+
+type ruleContext struct {
+  cik int
+  etfName string
+  // Whether the series is in our map.
+  known bool
+  index Index
+  // Only set for the kCrossFilingStage.
+  previous Index
+}
+
+type validationRule struct {
+  name string
+  stage ruleStage
+  defaults RuleConfig
+  // Returns the issues found, reported with the configured severity.
+  check func(ctx ruleContext, cfg RuleConfig) []string
+}
+
+func param(v float64) *float64 {
+  return &v
+}
+
+// componentRule returns a check reporting `message` for each component matching `matches`.
+func componentRule(matches func (c IndexComponent, cfg RuleConfig) bool, message string) func(ctx ruleContext, cfg RuleConfig) []string {
+  return func(ctx ruleContext, cfg RuleConfig) []string {
+    issues := []string{}
+    for _, c := range ctx.index.Components {
+      if matches(c, cfg) {
+        issues = append(issues, fmt.Sprintf("ETF %s has a component %s name=%s, id=%s, id_type=%s, weight=%f", ctx.etfName, message, c.Name, c.Id, c.IdType, c.Weight))
+      }
+    }
+    return issues
+  }
+}
+
+func hasId(c IndexComponent) bool {
+  return c.Id != "N/A" && c.Id != ""
+}
+
+func hasIdType(c IndexComponent) bool {
+  return c.IdType != "N/A" && c.IdType != ""
+}
+
+func changedPrefix(ctx ruleContext) string {
+  return fmt.Sprintf("ETF %s changed suspiciously between %s and %s:", ctx.etfName, ctx.previous.FilingDate, ctx.index.FilingDate)
+}
+
+// All the validation rules, run in order.
+// The parameters used by each rule are listed in their defaults.
+var kValidationRules = []validationRule{
+  // Series rules.
+  {"index_name", kSeriesStage, RuleConfig{Severity: "error"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if ctx.index.Name == "" || ctx.index.Name == "N/A" {
+      return []string{"Index is missing name"}
+    }
+    return nil
+  }},
+  {"series_id", kSeriesStage, RuleConfig{Severity: "error"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if ctx.index.SeriesId == "" {
+      return []string{fmt.Sprintf("Index %s is missing seriesName", ctx.index.Name)}
+    }
+    return nil
+  }},
+  // The series that aren't in our map, e.g. the funds of the CIK without an ETF, aren't errors by default as
+  // they would fail every fetch. They aren't written either way.
+  {"unknown_series", kSeriesStage, RuleConfig{Severity: "warning"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if !ctx.known {
+      return []string{fmt.Sprintf("Index %s doesn't have a corresponding ETF in our map", ctx.index.Name)}
+    }
+    return nil
+  }},
+  // Only for the series in our map, the others are reported by unknown_series.
+  {"empty_etf_name", kSeriesStage, RuleConfig{Severity: "error"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if ctx.known && ctx.etfName == "" {
+      return []string{fmt.Sprintf("Empty name in for index %s in our map", ctx.index.Name)}
+    }
+    return nil
+  }},
+
+  // Index rules.
+  {"duplicate_id", kIndexStage, RuleConfig{Severity: "warning"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    issues := []string{}
+    duplicates := duplicateIds(ctx.index)
+    for _, id := range slices.Sorted(maps.Keys(duplicates)) {
+      issues = append(issues, fmt.Sprintf("ETF %s has %d components with the same identifier %s", ctx.etfName, duplicates[id], id))
+    }
+    return issues
+  }},
+  // Sum of the weights, in percent, must be in [min, max].
+  {"weight_sum", kIndexStage, RuleConfig{Severity: "off", Min: param(95), Max: param(105)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    total := totalWeight(ctx.index)
+    if total < *cfg.Min || total > *cfg.Max {
+      return []string{fmt.Sprintf("ETF %s has a total weight of %.2f, outside of [%.2f, %.2f]", ctx.etfName, total, *cfg.Min, *cfg.Max)}
+    }
+    return nil
+  }},
+  // Absolute residual of the weights to 100% (see WeightTotals), in percentage points, must be at most `max`.
+  {"weight_residual", kIndexStage, RuleConfig{Severity: "warning", Max: param(5)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    // The indexes fetched before the reconciliation don't have it.
+    if ctx.index.Weights == nil {
+      return nil
+    }
+    if math.Abs(float64(ctx.index.Weights.Residual)) > *cfg.Max {
+      return []string{fmt.Sprintf("ETF %s has a residual weight of %.2f (components=%.2f, derivatives=%.2f), more than %.2f away from 100%%", ctx.etfName, ctx.index.Weights.Residual, ctx.index.Weights.Components, ctx.index.Weights.Derivatives, *cfg.Max)}
+    }
+    return nil
+  }},
+  {"component_name", kIndexStage, RuleConfig{Severity: "error"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return c.Name == "N/A" || c.Name == ""
+  }, "with no name")},
+  {"component_id", kIndexStage, RuleConfig{Severity: "error"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return !hasId(c)
+  }, "with no id")},
+  {"component_id_type", kIndexStage, RuleConfig{Severity: "error"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return !hasIdType(c)
+  }, "with no idType")},
+  {"synthetic_id", kIndexStage, RuleConfig{Severity: "warning"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return c.IdType == kSyntheticIdType
+  }, "without identifier, using a synthetic one")},
+  // The id types in `values` are known. This is mostly a signal for the users.
+  {"unknown_id_type", kIndexStage, RuleConfig{Severity: "warning", Values: []string{"isin", "ticker", "sedol", "faid", "cins", "cusip", "vid"}}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return hasIdType(c) && c.IdType != kSyntheticIdType && !slices.Contains(cfg.Values, c.IdType)
+  }, "with an unknown idType")},
+  {"malformed_id", kIndexStage, RuleConfig{Severity: "warning"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    issues := []string{}
+    for _, c := range ctx.index.Components {
+      if !hasId(c) {
+        continue
+      }
+      if err := validateIdentifier(c.Id, c.IdType); err != nil {
+        issues = append(issues, fmt.Sprintf("ETF %s has a component with a malformed id (%s) name=%s, id=%s, id_type=%s", ctx.etfName, err, c.Name, c.Id, c.IdType))
+      }
+    }
+    return issues
+  }},
+  {"negative_weight", kIndexStage, RuleConfig{Severity: "error"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return c.Weight < 0
+  }, "with negative weight")},
+  // Zero weights are valid for closed positions.
+  {"zero_weight", kIndexStage, RuleConfig{Severity: "off"}, componentRule(func (c IndexComponent, cfg RuleConfig) bool {
+    return c.Weight == 0
+  }, "with zero weight")},
+
+  // Cross-filing rules.
+  {"series_name_change", kCrossFilingStage, RuleConfig{Severity: "warning"}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if ctx.previous.Name != ctx.index.Name {
+      return []string{fmt.Sprintf("%s the series name changed from %s to %s", changedPrefix(ctx), ctx.previous.Name, ctx.index.Name)}
+    }
+    return nil
+  }},
+  // Relative change in the number of components must be at most `max`.
+  {"component_count_change", kCrossFilingStage, RuleConfig{Severity: "warning", Max: param(0.2)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    previousCount := len(ctx.previous.Components)
+    count := len(ctx.index.Components)
+    if previousCount > 0 && math.Abs(float64(count - previousCount)) / float64(previousCount) > *cfg.Max {
+      return []string{fmt.Sprintf("%s the number of components went from %d to %d", changedPrefix(ctx), previousCount, count)}
+    }
+    return nil
+  }},
+  // Absolute change in the sum of the weights, in percentage points, must be at most `max`.
+  {"total_weight_change", kCrossFilingStage, RuleConfig{Severity: "warning", Max: param(5)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    previousTotal := totalWeight(ctx.previous)
+    total := totalWeight(ctx.index)
+    if math.Abs(total - previousTotal) > *cfg.Max {
+      return []string{fmt.Sprintf("%s the total weight went from %.2f to %.2f", changedPrefix(ctx), previousTotal, total)}
+    }
+    return nil
+  }},
+  // The `max` top holdings of the previous filing must still be present.
+  {"top_holding_disappeared", kCrossFilingStage, RuleConfig{Severity: "warning", Max: param(5)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    ids := map[string]bool{}
+    for _, component := range ctx.index.Components {
+      ids[component.Id] = true
+    }
+    issues := []string{}
+    // The components are sorted by decreasing weight.
+    for _, component := range ctx.previous.Components[:min(int(*cfg.Max), len(ctx.previous.Components))] {
+      if !ids[component.Id] {
+        issues = append(issues, fmt.Sprintf("%s the top holding name=%s, id=%s, weight=%f disappeared", changedPrefix(ctx), component.Name, component.Id, component.Weight))
+      }
+    }
+    return issues
+  }},
+  // Absolute change in the share of each id type among the components must be at most `max`.
+  {"id_type_mix_change", kCrossFilingStage, RuleConfig{Severity: "warning", Max: param(0.1)}, func(ctx ruleContext, cfg RuleConfig) []string {
+    if len(ctx.previous.Components) == 0 || len(ctx.index.Components) == 0 {
+      return nil
+    }
+    issues := []string{}
+    previousShares := idTypeShares(ctx.previous)
+    shares := idTypeShares(ctx.index)
+    for _, idType := range sortedKeys(previousShares, shares) {
+      if math.Abs(shares[idType] - previousShares[idType]) > *cfg.Max {
+        issues = append(issues, fmt.Sprintf("%s the share of id_type=%s went from %.2f to %.2f", changedPrefix(ctx), idType, previousShares[idType], shares[idType]))
+      }
+    }
+    return issues
+  }},
+}
+
+// runRules runs the rules of `stage` and records their issues in `res`.
+func runRules(res *ValidationResult, stage ruleStage, ctx ruleContext) {
+  for _, rule := range kValidationRules {
+    if rule.stage != stage {
+      continue
+    }
+    cfg := validationConfig.ruleConfig(rule, ctx.etfName)
+    // The severities are checked when loading the configuration.
+    severity, _ := parseSeverity(cfg.Severity)
+    if severity == kSeverityNone {
+      continue
+    }
+    for _, issue := range rule.check(ctx, cfg) {
+      if severity == kSeverityError {
+        res.addError(issue)
+      } else {
+        res.addWarning(issue)
+      }
+    }
+  }
+}
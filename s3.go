@@ -0,0 +1,155 @@
+package main
+
+import (
+  "bytes"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "io"
+  "io/fs"
+  "maps"
+  "net/http"
+  "os"
+  "slices"
+  "strings"
+  "time"
+)
+
+// Used when $AWS_REGION isn't set. Most S3-compatible servers ignore the region.
+const kDefaultS3Region = "us-east-1"
+
+// s3Client is a minimal client for the object API of S3 and compatible servers (e.g. MinIO), with
+// path-style URLs (`<endpoint>/<bucket>/<key>`) and Signature Version 4 authentication.
+type s3Client struct {
+  // e.g. "https://s3.us-east-1.amazonaws.com" or "http://localhost:9000".
+  endpoint string
+  bucket string
+  region string
+  accessKey string
+  secretKey string
+  client *http.Client // mandatory
+  // Overridden by the tests.
+  now func () time.Time
+}
+
+// newS3ClientFromEnv returns a client using the credentials in $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY.
+func newS3ClientFromEnv(endpoint, bucket string) (*s3Client, error) {
+  accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+  if accessKey == "" || secretKey == "" {
+    return nil, fmt.Errorf("no \"$AWS_ACCESS_KEY_ID\" or \"$AWS_SECRET_ACCESS_KEY\" in the environment")
+  }
+  region := os.Getenv("AWS_REGION")
+  if region == "" {
+    region = kDefaultS3Region
+  }
+  return &s3Client{strings.TrimSuffix(endpoint, "/"), bucket, region, accessKey, secretKey, &http.Client{Timeout: time.Minute}, time.Now}, nil
+}
+
+// awsUriEncode percent-encodes `s` as required by the signature: everything but the unreserved characters
+// (and the slashes of a path).
+func awsUriEncode(s string, encodeSlash bool) string {
+  res := strings.Builder{}
+  for _, c := range []byte(s) {
+    switch {
+      case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+        res.WriteByte(c)
+      case c == '/' && !encodeSlash:
+        res.WriteByte(c)
+      default:
+        fmt.Fprintf(&res, "%%%02X", c)
+    }
+  }
+  return res.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+  h := hmac.New(sha256.New, key)
+  h.Write([]byte(data))
+  return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+  sum := sha256.Sum256(data)
+  return hex.EncodeToString(sum[:])
+}
+
+// signS3Request adds the Signature Version 4 headers to `req`, whose body has the SHA-256 `payloadHash`.
+// All the headers of `req` are signed, along with the host.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signS3Request(req *http.Request, payloadHash string, now time.Time, region, accessKey, secretKey string) {
+  amzDate := now.UTC().Format("20060102T150405Z")
+  scope := fmt.Sprintf("%s/%s/s3/aws4_request", amzDate[:8], region)
+  req.Header.Set("X-Amz-Date", amzDate)
+  req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+  headers := map[string]string{"host": req.URL.Host}
+  for name, values := range req.Header {
+    headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+  }
+  names := slices.Sorted(maps.Keys(headers))
+  canonicalHeaders := strings.Builder{}
+  for _, name := range names {
+    canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+  }
+  signedHeaders := strings.Join(names, ";")
+
+  // The requests don't have query parameters.
+  canonicalRequest := strings.Join([]string{req.Method, awsUriEncode(req.URL.Path, false), "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+  stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+  key := hmacSha256([]byte("AWS4" + secretKey), amzDate[:8])
+  for _, part := range []string{region, "s3", "aws4_request"} {
+    key = hmacSha256(key, part)
+  }
+  signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+  req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// do sends a request for the object `key` and returns the response body. A missing object returns fs.ErrNotExist.
+func (c *s3Client) do(method, key string, body []byte) ([]byte, error) {
+  path := "/" + c.bucket + "/" + key
+  req, err := http.NewRequest(method, c.endpoint + awsUriEncode(path, false), bytes.NewReader(body))
+  if err != nil {
+    return nil, err
+  }
+  if body != nil {
+    req.Header.Set("Content-Type", "application/json")
+  }
+  signS3Request(req, sha256Hex(body), c.now(), c.region, c.accessKey, c.secretKey)
+
+  resp, err := c.client.Do(req)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+  respBody, err := io.ReadAll(resp.Body)
+  if err != nil {
+    return nil, err
+  }
+  if resp.StatusCode == http.StatusNotFound {
+    return nil, fmt.Errorf("%s s3://%s/%s: %w", method, c.bucket, key, fs.ErrNotExist)
+  }
+  if resp.StatusCode / 100 != 2 {
+    return nil, fmt.Errorf("%s s3://%s/%s failed with status=%d, body=%s", method, c.bucket, key, resp.StatusCode, respBody)
+  }
+  return respBody, nil
+}
+
+func (c *s3Client) getObject(key string) ([]byte, error) {
+  return c.do(http.MethodGet, key, nil)
+}
+
+func (c *s3Client) putObject(key string, bytes []byte) error {
+  _, err := c.do(http.MethodPut, key, bytes)
+  return err
+}
+
+// deleteObject succeeds if the object doesn't exist.
+func (c *s3Client) deleteObject(key string) error {
+  _, err := c.do(http.MethodDelete, key, nil)
+  if errors.Is(err, fs.ErrNotExist) {
+    return nil
+  }
+  return err
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+  "embed"
+  "encoding/json"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+  "fmt"
+  "maps"
+  "math"
+  "reflect"
+  "regexp"
+  "slices"
+  "strings"
+  "sync"
+)
+
+// Version of the output format, written as `schema_version` in the outputs.
+// Bump it (and publish a new schema directory) on any incompatible format change.
+const kSchemaVersion = etf_data.SchemaVersion
+
+// The published JSON Schemas of the outputs.
+const kIndexSchema = "index.schema.json"
+const kAllSchema = "all.schema.json"
+const kFetchedMapSchema = "fetched_map.schema.json"
+const kDeltaHistorySchema = "delta_history.schema.json"
+const kFilingsIndexSchema = "filings_index.schema.json"
+const kManifestSchema = "manifest.schema.json"
+
+//go:embed schema/v1/*.json
+var kSchemaFiles embed.FS
+
+// Number of issues reported when a value doesn't match its schema.
+// The first issues are usually enough to understand what's wrong.
+const kMaxSchemaIssues = 10
+
+// The parsed schemas, keyed by file name. They are loaded once and only read afterwards.
+var schemas map[string]map[string]any
+var schemasErr error
+var loadSchemasOnce sync.Once
+
+// The keywords supported by checkSchema, plus the annotations which don't affect the validation.
+var kSchemaKeywords = []string{"$schema", "$id", "$defs", "title", "description", "$ref", "type", "const", "enum", "minimum", "pattern", "items", "properties", "patternProperties", "required", "additionalProperties"}
+
+// checkSchemaKeywords checks that `schema`, at `path` in the schema `file`, only uses the supported keywords,
+// so that a schema can't silently rely on a check that isn't implemented.
+func checkSchemaKeywords(file string, schema map[string]any, path string) error {
+  for _, keyword := range slices.Sorted(maps.Keys(schema)) {
+    if !slices.Contains(kSchemaKeywords, keyword) {
+      return fmt.Errorf("unsupported keyword %s at %s in schema %s", keyword, path, file)
+    }
+  }
+  if schemaType, ok := schema["type"]; ok {
+    if t, _ := schemaType.(string); !slices.Contains([]string{"object", "array", "string", "number", "integer", "boolean", "null"}, t) {
+      return fmt.Errorf("unsupported type %v at %s in schema %s", schemaType, path, file)
+    }
+  }
+  // The subschemas, keyed by their path.
+  subschemas := map[string]any{}
+  for _, keyword := range []string{"$defs", "properties", "patternProperties"} {
+    if _, ok := schema[keyword]; !ok {
+      continue
+    }
+    children, ok := schema[keyword].(map[string]any)
+    if !ok {
+      return fmt.Errorf("%s at %s in schema %s isn't an object", keyword, path, file)
+    }
+    for name, child := range children {
+      subschemas[path + "/" + keyword + "/" + name] = child
+    }
+  }
+  if items, ok := schema["items"]; ok {
+    subschemas[path + "/items"] = items
+  }
+  if additional, ok := schema["additionalProperties"]; ok {
+    if _, ok := additional.(bool); !ok {
+      subschemas[path + "/additionalProperties"] = additional
+    }
+  }
+  for _, subpath := range slices.Sorted(maps.Keys(subschemas)) {
+    subschema, ok := subschemas[subpath].(map[string]any)
+    if !ok {
+      return fmt.Errorf("%s in schema %s isn't a schema", subpath, file)
+    }
+    if err := checkSchemaKeywords(file, subschema, subpath); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// loadSchemas parses and checks all the embedded schemas.
+func loadSchemas() (map[string]map[string]any, error) {
+  dir := fmt.Sprintf("schema/v%d", kSchemaVersion)
+  entries, err := kSchemaFiles.ReadDir(dir)
+  if err != nil {
+    return nil, err
+  }
+  res := map[string]map[string]any{}
+  for _, entry := range entries {
+    bytes, err := kSchemaFiles.ReadFile(dir + "/" + entry.Name())
+    if err != nil {
+      return nil, err
+    }
+    schema := map[string]any{}
+    if err := json.Unmarshal(bytes, &schema); err != nil {
+      return nil, fmt.Errorf("parsing schema %s: %w", entry.Name(), err)
+    }
+    if err := checkSchemaKeywords(entry.Name(), schema, "#"); err != nil {
+      return nil, err
+    }
+    res[entry.Name()] = schema
+  }
+  return res, nil
+}
+
+func loadSchema(name string) (map[string]any, error) {
+  loadSchemasOnce.Do(func () {
+    schemas, schemasErr = loadSchemas()
+  })
+  if schemasErr != nil {
+    return nil, schemasErr
+  }
+  schema, ok := schemas[name]
+  if !ok {
+    return nil, fmt.Errorf("unknown schema %s", name)
+  }
+  return schema, nil
+}
+
+// resolveRef resolves `ref` (e.g. "#/$defs/date" or "index.schema.json") relative to the schema `file`.
+func resolveRef(file, ref string) (string, map[string]any, error) {
+  refFile, pointer, _ := strings.Cut(ref, "#")
+  if refFile == "" {
+    refFile = file
+  }
+  schema, err := loadSchema(refFile)
+  if err != nil {
+    return "", nil, err
+  }
+  if pointer == "" {
+    return refFile, schema, nil
+  }
+  for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+    next, ok := schema[part].(map[string]any)
+    if !ok {
+      return "", nil, fmt.Errorf("unresolved reference %s in schema %s", ref, file)
+    }
+    schema = next
+  }
+  return refFile, schema, nil
+}
+
+func matchesType(schemaType string, v any) bool {
+  switch schemaType {
+    case "object":
+      _, ok := v.(map[string]any)
+      return ok
+    case "array":
+      _, ok := v.([]any)
+      return ok
+    case "string":
+      _, ok := v.(string)
+      return ok
+    case "number":
+      _, ok := v.(float64)
+      return ok
+    case "integer":
+      f, ok := v.(float64)
+      return ok && f == math.Trunc(f)
+    case "boolean":
+      _, ok := v.(bool)
+      return ok
+    case "null":
+      return v == nil
+  }
+  return false
+}
+
+// checkSchema appends to `issues` the reasons why `v`, at `path`, doesn't match `schema`.
+// Only the subset of JSON Schema used by our schemas is supported: $ref, type, const, enum, minimum,
+// pattern, items, properties, patternProperties, required and additionalProperties.
+// The returned error is for invalid schemas.
+func checkSchema(file string, schema map[string]any, v any, path string, issues *[]string) error {
+  if ref, ok := schema["$ref"].(string); ok {
+    refFile, refSchema, err := resolveRef(file, ref)
+    if err != nil {
+      return err
+    }
+    if err := checkSchema(refFile, refSchema, v, path, issues); err != nil {
+      return err
+    }
+  }
+  if schemaType, ok := schema["type"].(string); ok && !matchesType(schemaType, v) {
+    *issues = append(*issues, fmt.Sprintf("%s: expected %s but got %v", path, schemaType, v))
+    return nil
+  }
+  if expected, ok := schema["const"]; ok && !reflect.DeepEqual(expected, v) {
+    *issues = append(*issues, fmt.Sprintf("%s: expected %v but got %v", path, expected, v))
+  }
+  if values, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(values, func (value any) bool { return reflect.DeepEqual(value, v) }) {
+    *issues = append(*issues, fmt.Sprintf("%s: %v isn't one of %v", path, v, values))
+  }
+
+  switch value := v.(type) {
+    case float64:
+      if minimum, ok := schema["minimum"].(float64); ok && value < minimum {
+        *issues = append(*issues, fmt.Sprintf("%s: %v is less than %v", path, value, minimum))
+      }
+    case string:
+      if pattern, ok := schema["pattern"].(string); ok {
+        matched, err := regexp.MatchString(pattern, value)
+        if err != nil {
+          return fmt.Errorf("invalid pattern %s in schema %s: %w", pattern, file, err)
+        }
+        if !matched {
+          *issues = append(*issues, fmt.Sprintf("%s: %q doesn't match %s", path, value, pattern))
+        }
+      }
+    case []any:
+      if items, ok := schema["items"].(map[string]any); ok {
+        for i, item := range value {
+          if err := checkSchema(file, items, item, fmt.Sprintf("%s[%d]", path, i), issues); err != nil {
+            return err
+          }
+        }
+      }
+    case map[string]any:
+      if required, ok := schema["required"].([]any); ok {
+        for _, name := range required {
+          if _, ok := value[name.(string)]; !ok {
+            *issues = append(*issues, fmt.Sprintf("%s: missing required property %s", path, name))
+          }
+        }
+      }
+      properties, _ := schema["properties"].(map[string]any)
+      patternProperties, _ := schema["patternProperties"].(map[string]any)
+      for _, key := range slices.Sorted(maps.Keys(value)) {
+        propertyPath := fmt.Sprintf("%s.%s", path, key)
+        matched := false
+        if property, ok := properties[key].(map[string]any); ok {
+          matched = true
+          if err := checkSchema(file, property, value[key], propertyPath, issues); err != nil {
+            return err
+          }
+        }
+        for pattern, property := range patternProperties {
+          ok, err := regexp.MatchString(pattern, key)
+          if err != nil {
+            return fmt.Errorf("invalid pattern %s in schema %s: %w", pattern, file, err)
+          }
+          if !ok {
+            continue
+          }
+          matched = true
+          if err := checkSchema(file, property.(map[string]any), value[key], propertyPath, issues); err != nil {
+            return err
+          }
+        }
+        if matched {
+          continue
+        }
+        switch additional := schema["additionalProperties"].(type) {
+          case bool:
+            if !additional {
+              *issues = append(*issues, fmt.Sprintf("%s: unexpected property", propertyPath))
+            }
+          case map[string]any:
+            if err := checkSchema(file, additional, value[key], propertyPath, issues); err != nil {
+              return err
+            }
+        }
+      }
+  }
+  return nil
+}
+
+// validateSchema checks that the JSON encoding of `v` matches the schema `name`.
+func validateSchema(name string, v any) error {
+  bytes, err := json.Marshal(v)
+  if err != nil {
+    return err
+  }
+  var doc any
+  if err := json.Unmarshal(bytes, &doc); err != nil {
+    return err
+  }
+  schema, err := loadSchema(name)
+  if err != nil {
+    return err
+  }
+
+  issues := []string{}
+  if err := checkSchema(name, schema, doc, "$", &issues); err != nil {
+    return err
+  }
+  if len(issues) > 0 {
+    return fmt.Errorf("doesn't match schema %s (%d issue(s)): %s", name, len(issues), strings.Join(issues[:min(kMaxSchemaIssues, len(issues))], "; "))
+  }
+  return nil
+}
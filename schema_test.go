@@ -0,0 +1,106 @@
+package main
+
+import (
+  "encoding/json"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+  component := IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 0.5}
+  bond := IndexComponent{Name: "United States Treasury Strip Coupon", Id: "US912834PZ59", IdType: "isin", Weight: 0.5, Debt: &DebtInfo{MaturityDate: "2050-02-15", CouponKind: "none"}}
+  tt := []struct {
+    name string
+    schema string
+    value any
+    expectedIssue string
+  } {
+    {"Valid index", kIndexSchema, Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{component, bond}}, ""},
+    {"Valid indexes", kAllSchema, []Index{Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}}, ""},
+    {"Valid fetched map", kFetchedMapSchema, FetchedDatesMap{kCompanyId: FilingDateSpan{Start: "2019-11-27", End: "2025-08-27"}}, ""},
+    {"Missing schema version", kIndexSchema, Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}, "$.schema_version: expected 1"},
+    {"Missing schema version in indexes", kAllSchema, []Index{Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}}, "$[0].schema_version: expected 1"},
+    {"Invalid filing date", kIndexSchema, Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: "01/01/2025", Components: []IndexComponent{}}, "$.filing_date: \"01/01/2025\" doesn't match"},
+    {"Missing components", kIndexSchema, Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate}, "$.components: expected array"},
+    {"Unexpected property", kIndexSchema, map[string]any{"schema_version": 1, "name": "Index", "series_id": kValidSeriesId, "filing_date": kDate, "components": []any{}, "total": 100}, "$.total: unexpected property"},
+    {"Wrong type in a component", kIndexSchema, map[string]any{"schema_version": 1, "name": "Index", "series_id": kValidSeriesId, "filing_date": kDate, "components": []any{map[string]any{"name": "Company", "id": "JPY", "id_type": "ticker", "weight": "0.5"}}}, "$.components[0].weight: expected number"},
+    {"Missing property in the debt", kIndexSchema, map[string]any{"schema_version": 1, "name": "Index", "series_id": kValidSeriesId, "filing_date": kDate, "components": []any{map[string]any{"name": "Company", "id": "JPY", "id_type": "ticker", "weight": 0.5, "debt": map[string]any{}}}}, "$.components[0].debt: missing required property maturity_date"},
+    {"Invalid CIK in the fetched map", kFetchedMapSchema, map[string]any{"cik": map[string]any{"start": "", "end": ""}}, "$.cik: unexpected property"},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      err := validateSchema(tc.schema, tc.value)
+      if tc.expectedIssue == "" && err != nil {
+        t.Errorf("Expected a match but got err=%+v", err)
+      }
+      if tc.expectedIssue != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedIssue)) {
+        t.Errorf("Expected an issue containing %q but got err=%+v", tc.expectedIssue, err)
+      }
+    })
+  }
+}
+
+func TestFetchedDatesMapJson(t *testing.T) {
+  // Readers decode fetched_map.json as a map keyed by CIK.
+  m := FetchedDatesMap{kCompanyId: FilingDateSpan{Start: "2019-11-27", End: "2025-08-27"}}
+  bytes, err := json.Marshal(m)
+  if err != nil {
+    t.Errorf("Failed to marshal the fetched map (err=%+v)", err)
+    return
+  }
+  parsed := map[int]FilingDateSpan{}
+  if err := json.Unmarshal(bytes, &parsed); err != nil || !reflect.DeepEqual(FetchedDatesMap(parsed), m) {
+    t.Errorf("Mismatched fetched map %s (err=%+v)", bytes, err)
+  }
+}
+
+func TestCheckSchemaKeywords(t *testing.T) {
+  tt := []struct {
+    name string
+    schema string
+    // Empty if the schema is supported.
+    expectedError string
+  } {
+    {"Supported", `{"type": "object", "properties": {"id": {"type": "string", "pattern": "^[0-9]+$"}}, "$defs": {"date": {"type": "string"}}}`, ""},
+    {"Unsupported keyword", `{"type": "string", "maxLength": 10}`, "unsupported keyword maxLength at #"},
+    {"Unsupported keyword in a property", `{"properties": {"id": {"type": "string", "format": "date"}}}`, "unsupported keyword format at #/properties/id"},
+    {"Unsupported keyword in the items", `{"items": {"oneOf": []}}`, "unsupported keyword oneOf at #/items"},
+    {"Unsupported keyword in a definition", `{"$defs": {"date": {"minLength": 1}}}`, "unsupported keyword minLength at #/$defs/date"},
+    {"Several types", `{"type": ["string", "null"]}`, "unsupported type"},
+    {"Invalid subschema", `{"properties": {"id": "string"}}`, "#/properties/id in schema test.json isn't a schema"},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      schema := map[string]any{}
+      if err := json.Unmarshal([]byte(tc.schema), &schema); err != nil {
+        t.Errorf("Failed to parse the schema (err=%+v)", err)
+        return
+      }
+      err := checkSchemaKeywords("test.json", schema, "#")
+      if tc.expectedError == "" && err != nil {
+        t.Errorf("Expected a supported schema but got err=%+v", err)
+      }
+      if tc.expectedError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectedError)) {
+        t.Errorf("Expected an error containing %q but got err=%+v", tc.expectedError, err)
+      }
+    })
+  }
+}
+
+func TestValidateSchemaConcurrently(t *testing.T) {
+  indexes := []Index{Index{SchemaVersion: kSchemaVersion, Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}}
+  errs := make(chan error)
+  for range 4 {
+    go func() {
+      errs <- validateSchema(kAllSchema, indexes)
+    }()
+  }
+  for range 4 {
+    if err := <-errs; err != nil {
+      t.Errorf("Failed to validate (err=%+v)", err)
+    }
+  }
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "flag"
+  "fmt"
+  "io/fs"
+  "os"
+  "path"
+  "path/filepath"
+  "strings"
+  "sync"
+
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+)
+
+const kFetchedMapFileName = "fetched_map.json"
+
+// Storage holds the data that the fetch reads and writes: the history and the performance of each ETF and
+// the fetch state (the fetched dates, see fetched_map.json).
+type Storage interface {
+  // ReadHistory returns the filings of `etf` from the newest to the oldest, or fs.ErrNotExist.
+  ReadHistory(etf string) ([]Index, error)
+  // ReadPerformance returns the months of `etf` from the newest to the oldest, or fs.ErrNotExist.
+  ReadPerformance(etf string) ([]MonthlyPerformance, error)
+  ReadFetchState() (FetchedDatesMap, error)
+  // NewBatch starts a group of writes, which are only applied by its Commit.
+  NewBatch() StorageBatch
+}
+
+// StorageBatch is a group of writes to a Storage. Either Commit or Discard must be called.
+type StorageBatch interface {
+  // WriteHistory replaces the history of `etf` (in `historyFormat`) and its latest filing.
+  WriteHistory(etf string, indexes []Index) error
+  WritePerformance(etf string, months []MonthlyPerformance) error
+  // The fetch state is written last, so that readers never see fetched dates without their filings.
+  WriteFetchState(state FetchedDatesMap) error
+  Commit() error
+  Discard()
+}
+
+// Storage that the fetch and the commands read from and that the fetch writes to.
+var storage Storage = newFileStorage("./data")
+
+func latestPath(dataDir, etf string) string {
+  return filepath.Join(dataDir, etf_data.LatestPath(etf))
+}
+
+func performancePath(etf string) string {
+  return path.Join("performance", etf + ".json")
+}
+
+// fileStorage is the data directory, with the layout described in the README.
+type fileStorage struct {
+  dataDir string
+}
+
+func newFileStorage(dataDir string) *fileStorage {
+  return &fileStorage{dataDir}
+}
+
+func (s *fileStorage) ReadHistory(etf string) ([]Index, error) {
+  return readHistory(s.dataDir, etf)
+}
+
+func (s *fileStorage) ReadPerformance(etf string) ([]MonthlyPerformance, error) {
+  res := []MonthlyPerformance{}
+  err := readJsonFile(filepath.Join(s.dataDir, performancePath(etf)), &res)
+  return res, err
+}
+
+func (s *fileStorage) ReadFetchState() (FetchedDatesMap, error) {
+  res := FetchedDatesMap{}
+  err := readJsonFile(filepath.Join(s.dataDir, kFetchedMapFileName), &res)
+  return res, err
+}
+
+func (s *fileStorage) NewBatch() StorageBatch {
+  return &fileStorageBatch{s.dataDir, newFileBatch(filepath.Join(s.dataDir, filepath.Base(kJournalFile))), nil}
+}
+
+// fileStorageBatch stages the files in a file batch, committed atomically along with the by_filing files
+// (with `-by_filing`) and the manifest.
+type fileStorageBatch struct {
+  dataDir string
+  batch *fileBatch
+  // Staged on commit, after the other files.
+  fetchState FetchedDatesMap
+}
+
+func (b *fileStorageBatch) WriteHistory(etf string, indexes []Index) error {
+  if err := stageHistory(b.batch, b.dataDir, etf, indexes); err != nil {
+    return err
+  }
+  if writeByFiling {
+    if err := stageByFiling(b.batch, b.dataDir, etf, indexes); err != nil {
+      return err
+    }
+  }
+  path := latestPath(b.dataDir, etf)
+  if err := b.batch.add(path, kIndexSchema, indexes[0]); err != nil {
+    return fmt.Errorf("writing to file %s: %w", path, err)
+  }
+  return nil
+}
+
+func (b *fileStorageBatch) WritePerformance(etf string, months []MonthlyPerformance) error {
+  path := filepath.Join(b.dataDir, performancePath(etf))
+  if err := b.batch.add(path, "", months); err != nil {
+    return fmt.Errorf("writing to file %s: %w", path, err)
+  }
+  return nil
+}
+
+func (b *fileStorageBatch) WriteFetchState(state FetchedDatesMap) error {
+  if err := validateSchema(kFetchedMapSchema, state); err != nil {
+    return fmt.Errorf("writing to file %s: %w", filepath.Join(b.dataDir, kFetchedMapFileName), err)
+  }
+  b.fetchState = state
+  return nil
+}
+
+func (b *fileStorageBatch) Commit() error {
+  if b.fetchState != nil {
+    if err := b.batch.add(filepath.Join(b.dataDir, kFetchedMapFileName), kFetchedMapSchema, b.fetchState); err != nil {
+      b.batch.discard()
+      return err
+    }
+  }
+  // Last, as it describes the other files.
+  if err := stageManifest(b.batch, b.dataDir); err != nil {
+    b.batch.discard()
+    return err
+  }
+  return b.batch.commit()
+}
+
+func (b *fileStorageBatch) Discard() {
+  b.batch.discard()
+}
+
+// objectStore is a flat store of the data files, keyed by their path in the data directory (e.g. "all/VOO.json").
+// A missing object returns fs.ErrNotExist.
+type objectStore interface {
+  getObject(key string) ([]byte, error)
+  putObject(key string, bytes []byte) error
+  // Succeeds if the object doesn't exist.
+  deleteObject(key string) error
+}
+
+// memoryStore keeps the objects in memory, for the tests.
+type memoryStore struct {
+  mutex sync.Mutex
+  objects map[string][]byte
+}
+
+func (m *memoryStore) getObject(key string) ([]byte, error) {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+  bytes, ok := m.objects[key]
+  if !ok {
+    return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+  }
+  return bytes, nil
+}
+
+func (m *memoryStore) putObject(key string, bytes []byte) error {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+  m.objects[key] = bytes
+  return nil
+}
+
+func (m *memoryStore) deleteObject(key string) error {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+  delete(m.objects, key)
+  return nil
+}
+
+// objectStorage lays the data out in an objectStore like in the data directory, under `prefix`.
+// The files are encoded like in the data directory, so they can be served as is.
+type objectStorage struct {
+  store objectStore
+  // Either empty or ending with a slash.
+  prefix string
+}
+
+func newMemoryStorage() *objectStorage {
+  return &objectStorage{&memoryStore{objects: map[string][]byte{}}, ""}
+}
+
+func newS3Storage(client *s3Client, prefix string) *objectStorage {
+  if prefix != "" && !strings.HasSuffix(prefix, "/") {
+    prefix += "/"
+  }
+  return &objectStorage{client, prefix}
+}
+
+// readJson decodes the object `key` into `v`.
+func (s *objectStorage) readJson(key string, v any) error {
+  bytes, err := s.store.getObject(s.prefix + key)
+  if err != nil {
+    return err
+  }
+  if err := json.Unmarshal(bytes, v); err != nil {
+    return fmt.Errorf("decoding %s: %w", key, err)
+  }
+  return nil
+}
+
+func (s *objectStorage) ReadHistory(etf string) ([]Index, error) {
+  full, delta := etf_data.HistoryPaths(etf)
+  key, isDelta := delta, true
+  bytes, err := s.store.getObject(s.prefix + key)
+  if errors.Is(err, fs.ErrNotExist) {
+    key, isDelta = full, false
+    bytes, err = s.store.getObject(s.prefix + key)
+  }
+  if err != nil {
+    return nil, err
+  }
+  indexes, err := etf_data.DecodeHistory(bytes, isDelta)
+  if err != nil {
+    return nil, fmt.Errorf("decoding %s: %w", key, err)
+  }
+  return indexes, nil
+}
+
+func (s *objectStorage) ReadPerformance(etf string) ([]MonthlyPerformance, error) {
+  res := []MonthlyPerformance{}
+  err := s.readJson(performancePath(etf), &res)
+  return res, err
+}
+
+func (s *objectStorage) ReadFetchState() (FetchedDatesMap, error) {
+  res := FetchedDatesMap{}
+  err := s.readJson(kFetchedMapFileName, &res)
+  return res, err
+}
+
+func (s *objectStorage) NewBatch() StorageBatch {
+  return &objectStorageBatch{s, []objectWrite{}, nil}
+}
+
+// objectWrite puts `bytes` to `key`, or deletes it if `bytes` is nil.
+type objectWrite struct {
+  key string
+  bytes []byte
+}
+
+// objectStorageBatch encodes the objects when they are written and uploads them on commit, as the object
+// stores don't have transactions. A history is followed by the removal of the history in the other format
+// and then by its latest filing, so readers see either the previous or the new history until the latest
+// filing is written. The fetch state comes last.
+type objectStorageBatch struct {
+  storage *objectStorage
+  writes []objectWrite
+  fetchState *objectWrite
+}
+
+// encode validates `v` against the JSON Schema `schema` (if not empty) and encodes it for `key`.
+func (b *objectStorageBatch) encode(key string, schema string, v any) (objectWrite, error) {
+  if schema != "" {
+    if err := validateSchema(schema, v); err != nil {
+      return objectWrite{}, fmt.Errorf("writing %s: %w", key, err)
+    }
+  }
+  bytes, err := marshalDataJson(v)
+  if err != nil {
+    return objectWrite{}, err
+  }
+  return objectWrite{key, bytes}, nil
+}
+
+func (b *objectStorageBatch) WriteHistory(etf string, indexes []Index) error {
+  full, delta := etf_data.HistoryPaths(etf)
+  key, schema, stale := full, kAllSchema, delta
+  var v any = indexes
+  if historyFormat == kDeltaHistory {
+    h, err := encodeDeltaHistory(indexes)
+    if err != nil {
+      return err
+    }
+    key, schema, stale, v = delta, kDeltaHistorySchema, full, h
+  }
+  history, err := b.encode(key, schema, v)
+  if err != nil {
+    return err
+  }
+  latest, err := b.encode(etf_data.LatestPath(etf), kIndexSchema, indexes[0])
+  if err != nil {
+    return err
+  }
+  b.writes = append(b.writes, history, objectWrite{stale, nil}, latest)
+  return nil
+}
+
+func (b *objectStorageBatch) WritePerformance(etf string, months []MonthlyPerformance) error {
+  write, err := b.encode(performancePath(etf), "", months)
+  if err != nil {
+    return err
+  }
+  b.writes = append(b.writes, write)
+  return nil
+}
+
+func (b *objectStorageBatch) WriteFetchState(state FetchedDatesMap) error {
+  write, err := b.encode(kFetchedMapFileName, kFetchedMapSchema, state)
+  if err != nil {
+    return err
+  }
+  b.fetchState = &write
+  return nil
+}
+
+func (b *objectStorageBatch) Commit() error {
+  writes := b.writes
+  if b.fetchState != nil {
+    writes = append(writes, *b.fetchState)
+  }
+  b.Discard()
+  for _, write := range writes {
+    key := b.storage.prefix + write.key
+    if write.bytes == nil {
+      if err := b.storage.store.deleteObject(key); err != nil {
+        return err
+      }
+      continue
+    }
+    if err := b.storage.store.putObject(key, write.bytes); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (b *objectStorageBatch) Discard() {
+  b.writes = nil
+  b.fetchState = nil
+}
+
+// publish copies the histories and the performance of `etfs` and then the fetch state from `from` to `to`.
+// The ETFs without history are skipped.
+func publish(from, to Storage, etfs []string) (int, error) {
+  batch := to.NewBatch()
+  published := 0
+  for _, etf := range etfs {
+    indexes, err := from.ReadHistory(etf)
+    if errors.Is(err, fs.ErrNotExist) {
+      continue
+    }
+    if err == nil {
+      err = batch.WriteHistory(etf, indexes)
+    }
+    if err != nil {
+      batch.Discard()
+      return 0, fmt.Errorf("publishing %s: %w", etf, err)
+    }
+    // Only populated for the filings fetched after its introduction.
+    months, err := from.ReadPerformance(etf)
+    if err == nil {
+      err = batch.WritePerformance(etf, months)
+    }
+    if err != nil && !errors.Is(err, fs.ErrNotExist) {
+      batch.Discard()
+      return 0, fmt.Errorf("publishing the performance of %s: %w", etf, err)
+    }
+    published++
+  }
+  state, err := from.ReadFetchState()
+  if err == nil {
+    err = batch.WriteFetchState(state)
+  }
+  if err != nil {
+    batch.Discard()
+    return 0, err
+  }
+  return published, batch.Commit()
+}
+
+// runPublish copies the data to an S3-compatible bucket, with the same layout as the data directory.
+func runPublish(args []string) error {
+  flags := flag.NewFlagSet("publish", flag.ExitOnError)
+  endpointFlag := flags.String("endpoint", "", "URL of the S3-compatible server, e.g. https://s3.us-east-1.amazonaws.com")
+  bucketFlag := flags.String("bucket", "", "Name of the bucket")
+  prefixFlag := flags.String("prefix", "", "Prefix of the keys, e.g. data/")
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to publish (default: all)")
+  historyFormatFlag := flags.String("history_format", kFullHistory, "Format of the published history files, either \"full\" or \"delta\"")
+  flags.Parse(args)
+  if *endpointFlag == "" || *bucketFlag == "" {
+    return fmt.Errorf("-endpoint and -bucket are required")
+  }
+  if *historyFormatFlag != kFullHistory && *historyFormatFlag != kDeltaHistory {
+    return fmt.Errorf("unknown history format %q", *historyFormatFlag)
+  }
+  historyFormat = *historyFormatFlag
+
+  client, err := newS3ClientFromEnv(*endpointFlag, *bucketFlag)
+  if err != nil {
+    return err
+  }
+  // The fetch may be writing the data directory.
+  lock, err := acquireDataLock(kLockFile)
+  if err != nil {
+    return err
+  }
+  defer lock.release() // ignore error; the lock becomes stale anyway
+  if err := recoverFileBatch(kJournalFile); err != nil {
+    return err
+  }
+
+  published, err := publish(storage, newS3Storage(client, *prefixFlag), selectEtfs(*etfsFlag))
+  if err != nil {
+    return err
+  }
+  fmt.Fprintf(os.Stderr, "Published %d ETFs to s3://%s/%s\n", published, *bucketFlag, *prefixFlag)
+  return nil
+}
@@ -7,7 +7,10 @@ import (
   "fmt"
   "io"
   "edgar_client"
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
   "os"
+  "slices"
+  "strconv"
 
   "golang.org/x/net/html"
 )
@@ -141,19 +144,58 @@ func processHTML(cik int, r io.Reader) (map[string]string, error) {
   }
 }
 
-type StoredIndex struct {
-  SeriesId string `json:"series_id"`
-  Name string `json:"name"`
+// Shared with the main package.
+type StoredIndex = etf_data.StoredIndex
+
+// marshalStoredIndexes encodes `m` like the data files (see marshalDataJson in the main package): indented,
+// with the CIKs sorted like json.Marshal does and a series per line, so that `git diff` shows the changed series.
+func marshalStoredIndexes(m map[int][]StoredIndex) ([]byte, error) {
+  keys := []string{}
+  for cik := range m {
+    keys = append(keys, strconv.Itoa(cik))
+  }
+  slices.Sort(keys)
+
+  res := bytes.Buffer{}
+  res.WriteString("{")
+  for i, key := range keys {
+    if i > 0 {
+      res.WriteString(",")
+    }
+    fmt.Fprintf(&res, "\n  %q: [", key)
+    cik, _ := strconv.Atoi(key)
+    for j, index := range m[cik] {
+      if j > 0 {
+        res.WriteString(",")
+      }
+      encoded, err := json.Marshal(index)
+      if err != nil {
+        return nil, err
+      }
+      res.WriteString("\n    ")
+      res.Write(encoded)
+    }
+    if len(m[cik]) > 0 {
+      res.WriteString("\n  ")
+    }
+    res.WriteString("]")
+  }
+  if len(keys) > 0 {
+    res.WriteString("\n")
+  }
+  res.WriteString("}\n")
+  return res.Bytes(), nil
 }
 
-func writeToJsonFile(path string, v any) error {
+func writeToJsonFile(path string, v map[int][]StoredIndex) error {
   f, err := os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_TRUNC, 0644)
   if err != nil {
     return err
   }
 
-  bytes, err := json.Marshal(v)
+  bytes, err := marshalStoredIndexes(v)
   if err != nil {
+    f.Close() // ignore error; marshal error takes precedence
     return err
   }
 
@@ -213,7 +255,7 @@ func main() {
 
     etfs := []StoredIndex{}
     for seriesId, name := range seriesToEtfMap {
-      etfs = append(etfs, StoredIndex{seriesId, name})
+      etfs = append(etfs, StoredIndex{SeriesId: seriesId, Name: name})
     }
     output[cik] = etfs
   }
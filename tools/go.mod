@@ -4,8 +4,11 @@ go 1.24.2
 
 replace edgar_client => ../edgar_client
 
+replace github.com/jchaffraix/vanguard_etfs/etf_data => ../etf_data
+
 require (
 	edgar_client v0.0.0-00010101000000-000000000000
+	github.com/jchaffraix/vanguard_etfs/etf_data v0.0.0-00010101000000-000000000000
 	golang.org/x/net v0.46.0
 )
 
@@ -0,0 +1,29 @@
+package main
+
+import (
+  "github.com/jchaffraix/vanguard_etfs/etf_data"
+)
+
+// The types of the stored data are defined in etf_data, which loads the data for the other programs.
+type Index = etf_data.Index
+type IndexComponent = etf_data.IndexComponent
+type ComponentLot = etf_data.ComponentLot
+type WeightTotals = etf_data.WeightTotals
+type DebtInfo = etf_data.DebtInfo
+type ConvertibleInfo = etf_data.ConvertibleInfo
+type ConvertibleReference = etf_data.ConvertibleReference
+type MaturityBucket = etf_data.MaturityBucket
+type BondAnalytics = etf_data.BondAnalytics
+type LendingInfo = etf_data.LendingInfo
+type Borrower = etf_data.Borrower
+type FundLending = etf_data.FundLending
+type MaturityRisk = etf_data.MaturityRisk
+type CurrencyRisk = etf_data.CurrencyRisk
+type RiskMetrics = etf_data.RiskMetrics
+type Gains = etf_data.Gains
+type Flows = etf_data.Flows
+type MonthlyPerformance = etf_data.MonthlyPerformance
+type StoredIndex = etf_data.StoredIndex
+type DeltaHistory = etf_data.DeltaHistory
+type IndexDelta = etf_data.IndexDelta
+type ComponentRef = etf_data.ComponentRef
@@ -0,0 +1,164 @@
+package main
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io/fs"
+  "maps"
+  "os"
+  "path/filepath"
+  "reflect"
+  "slices"
+  "strings"
+)
+
+// validateStoredIndexes checks the indexes stored for `etf`, ordered from the newest to the oldest.
+// Each index is validated with the per-filing rules and against the previous one with the cross-filing rules.
+// The storage-level issues (ordering, mismatched latest file) are reported in `res`.
+func validateStoredIndexes(res *ValidationResult, summary *RunSummary, cik int, indexes []Index, latest Index) {
+  // Filings whose series isn't the ETF's series in our map, keyed by series.
+  foreignSeries := map[string]int{}
+  for i, index := range indexes {
+    indexRes := validateIndex(cik, index)
+    if i + 1 < len(indexes) && indexRes.etfName != "" {
+      validateAgainstPrevious(&indexRes, indexes[i + 1], index)
+    }
+    if indexRes.etfName != res.etfName {
+      foreignSeries[index.SeriesId]++
+      // Report the filing under the ETF whose file contains it.
+      indexRes.etfName = res.etfName
+    }
+    summary.add(indexRes)
+
+    if i + 1 < len(indexes) {
+      switch strings.Compare(index.FilingDate, indexes[i + 1].FilingDate) {
+        case -1:
+          res.addError(fmt.Sprintf("ETF %s has its filings out of order: %s is stored before %s", res.etfName, index.FilingDate, indexes[i + 1].FilingDate))
+        case 0:
+          res.addWarning(fmt.Sprintf("ETF %s has several filings on %s", res.etfName, index.FilingDate))
+      }
+    }
+    for j := 1; j < len(index.Components); j++ {
+      if index.Components[j - 1].Weight < index.Components[j].Weight {
+        res.addError(fmt.Sprintf("ETF %s has its components out of order in the filing of %s: %s (weight=%f) is stored before %s (weight=%f)", res.etfName, index.FilingDate, index.Components[j - 1].Id, index.Components[j - 1].Weight, index.Components[j].Id, index.Components[j].Weight))
+        break
+      }
+    }
+  }
+
+  for _, seriesId := range slices.Sorted(maps.Keys(foreignSeries)) {
+    res.addError(fmt.Sprintf("ETF %s has %d filing(s) for series %s which isn't its series in our map", res.etfName, foreignSeries[seriesId], seriesId))
+  }
+  if len(indexes) > 0 && !reflect.DeepEqual(latest, indexes[0]) {
+    res.addError(fmt.Sprintf("ETF %s has a latest file (filing_date=%s) that differs from its newest filing (filing_date=%s)", res.etfName, latest.FilingDate, indexes[0].FilingDate))
+  }
+}
+
+// validateStoredData validates the data stored under `dataDir` for `etfs`.
+// It also reports the stored files that don't belong to any ETF in our map.
+func validateStoredData(dataDir string, etfs []string, summary *RunSummary) error {
+  etfToCik := map[string]int{}
+  for cik, cikEtfs := range cikToEtfs {
+    for _, etf := range cikEtfs {
+      etfToCik[etf] = cik
+    }
+  }
+
+  for _, etf := range etfs {
+    cik, ok := etfToCik[etf]
+    res := ValidationResult{etf, cik, "", "", []string{}, []string{}, []IndexComponent{}}
+    if !ok {
+      res.addError(fmt.Sprintf("ETF %s isn't in our map", etf))
+      summary.add(res)
+      continue
+    }
+
+    allPath, delta := historyFile(dataDir, etf)
+    indexes, err := readHistory(dataDir, etf)
+    if errors.Is(err, fs.ErrNotExist) {
+      res.addError(fmt.Sprintf("ETF %s has no file %s", etf, allPath))
+    } else if err != nil {
+      return fmt.Errorf("reading %s: %w", allPath, err)
+    } else if len(indexes) == 0 {
+      res.addError(fmt.Sprintf("ETF %s has no filing in %s", etf, allPath))
+    }
+    if full, _ := historyPaths(dataDir, etf); delta {
+      if _, err := os.Stat(full); err == nil {
+        res.addWarning(fmt.Sprintf("ETF %s has both %s and %s, only the latter is used", etf, full, allPath))
+      }
+    }
+
+    if err := validateByFiling(&res, dataDir, etf, indexes); err != nil {
+      return err
+    }
+
+    latest := Index{}
+    latestPath := filepath.Join(dataDir, "latest", etf + ".json")
+    if err := readJsonFile(latestPath, &latest); errors.Is(err, fs.ErrNotExist) {
+      res.addError(fmt.Sprintf("ETF %s has no file %s", etf, latestPath))
+      // Don't report the mismatch on top of the missing file.
+      indexes = []Index{}
+    } else if err != nil {
+      return fmt.Errorf("reading %s: %w", latestPath, err)
+    }
+
+    if len(indexes) > 0 {
+      res.seriesId = indexes[0].SeriesId
+    } else {
+      res.seriesId = latest.SeriesId
+    }
+    validateStoredIndexes(&res, summary, cik, indexes, latest)
+    summary.add(res)
+  }
+
+  // Files left behind, e.g. after an ETF is removed from our map.
+  for _, dir := range []string{"all", "latest", "performance", "by_filing"} {
+    entries, err := os.ReadDir(filepath.Join(dataDir, dir))
+    if errors.Is(err, fs.ErrNotExist) {
+      continue
+    }
+    if err != nil {
+      return err
+    }
+    for _, entry := range entries {
+      etf := strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".json"), ".delta")
+      if _, ok := etfToCik[etf]; !ok {
+        res := ValidationResult{"", 0, "", "", []string{}, []string{}, []IndexComponent{}}
+        res.addWarning(fmt.Sprintf("File %s doesn't belong to any ETF in our map", filepath.Join(dataDir, dir, entry.Name())))
+        summary.add(res)
+      }
+    }
+  }
+  return nil
+}
+
+// runValidate re-validates the stored data, e.g. after changing the validation rules or fixing the parser.
+// Like the fetch, the exit code reflects the worst severity (see validationIssuesError).
+func runValidate(args []string) error {
+  flags := flag.NewFlagSet("validate", flag.ExitOnError)
+  etfsFlag := flags.String("etfs", "", "Comma separated list of ETFs to validate. Defaults to all ETFs")
+  jsonReportFlag := flags.String("json_report", "", "Path to write the validation report as JSON")
+  junitReportFlag := flags.String("junit_report", "", "Path to write the validation report as JUnit XML")
+  flags.Parse(args)
+
+  etfs := selectEtfs(*etfsFlag)
+  summary := &RunSummary{}
+  if err := validateStoredData("./data", etfs, summary); err != nil {
+    return err
+  }
+  if err := validateManifest("./data", summary); err != nil {
+    return err
+  }
+  for _, res := range summary.results {
+    res.dump()
+  }
+  summary.dump()
+  if err := writeReports(summary, *jsonReportFlag, *junitReportFlag); err != nil {
+    return fmt.Errorf("writing the validation reports: %w", err)
+  }
+  if severity := summary.worstSeverity(); severity != kSeverityNone {
+    return validationIssuesError{severity}
+  }
+  return nil
+}
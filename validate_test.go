@@ -0,0 +1,93 @@
+package main
+
+import (
+  "errors"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestValidateStoredData(t *testing.T) {
+  newer := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{IndexComponent{Name: "Novartis AG", Id: "9024056", IdType: "sedol", Weight: 60}, IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 40}}}
+  older := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Novartis AG", Id: "9024056", IdType: "sedol", Weight: 55}, IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 45}}}
+  unordered := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: "2025-02-01", Components: []IndexComponent{IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 40}, IndexComponent{Name: "Novartis AG", Id: "9024056", IdType: "sedol", Weight: 60}}}
+  foreign := Index{Name: "Index", SeriesId: kInvalidSeriesId, FilingDate: "2025-02-01", Components: newer.Components}
+  tt := []struct {
+    name string
+    all []Index
+    latest *Index
+    orphan bool
+    expectedErrors int
+    expectedWarnings int
+  } {
+    {"Valid", []Index{newer, older}, &newer, false, 0, 0},
+    {"Latest differs from the newest filing", []Index{newer, older}, &older, false, 1, 0},
+    {"Filings out of order", []Index{older, newer}, &older, false, 1, 0},
+    {"Several filings on the same date", []Index{newer, newer, older}, &newer, false, 0, 1},
+    {"Components out of order", []Index{unordered, older}, &unordered, false, 1, 0},
+    {"Filings of another series", []Index{foreign, older}, &foreign, false, 1, 1},
+    {"Missing all file", nil, &newer, false, 1, 0},
+    {"Missing latest file", []Index{newer, older}, nil, false, 1, 0},
+    {"File without ETF", []Index{newer, older}, &newer, true, 0, 1},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      dir := t.TempDir()
+      for _, subdir := range []string{"all", "latest"} {
+        if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+          t.Errorf("Failed to create the data directory (err=%+v)", err)
+          return
+        }
+      }
+      if tc.all != nil {
+        if err := writeToJsonFile(filepath.Join(dir, "all", "VXF.json"), tc.all); err != nil {
+          t.Errorf("Failed to write the all file (err=%+v)", err)
+          return
+        }
+      }
+      if tc.latest != nil {
+        if err := writeToJsonFile(filepath.Join(dir, "latest", "VXF.json"), *tc.latest); err != nil {
+          t.Errorf("Failed to write the latest file (err=%+v)", err)
+          return
+        }
+      }
+      if tc.orphan {
+        if err := writeToJsonFile(filepath.Join(dir, "latest", "UNKNOWN.json"), newer); err != nil {
+          t.Errorf("Failed to write the orphan file (err=%+v)", err)
+          return
+        }
+      }
+
+      summary := &RunSummary{}
+      if err := validateStoredData(dir, []string{"VXF"}, summary); err != nil {
+        t.Errorf("Failed to validate the stored data (err=%+v)", err)
+        return
+      }
+      errorCount := 0
+      warnings := 0
+      for _, res := range summary.results {
+        errorCount += len(res.errors)
+        warnings += len(res.warnings)
+      }
+      if errorCount != tc.expectedErrors || warnings != tc.expectedWarnings {
+        t.Errorf("Expected %d errors and %d warnings but got %+v", tc.expectedErrors, tc.expectedWarnings, summary.results)
+      }
+    })
+  }
+}
+
+func TestRunValidate(t *testing.T) {
+  t.Chdir(t.TempDir())
+  for _, subdir := range []string{"all", "latest"} {
+    if err := os.MkdirAll(filepath.Join("data", subdir), 0755); err != nil {
+      t.Fatalf("Failed to create the data directory (err=%+v)", err)
+    }
+  }
+  // The files of VXF are missing.
+  err := runValidate([]string{"-etfs", "VXF"})
+  issues := validationIssuesError{}
+  if !errors.As(err, &issues) || issues.severity != kSeverityError || exitCode(issues.severity) != kExitCodeErrors {
+    t.Errorf("Expected validation errors but got err=%+v", err)
+  }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+  "fmt"
+)
+
+type Severity int
+const (
+  kSeverityNone Severity = iota
+  kSeverityWarning
+  kSeverityError
+)
+func (s Severity) String() string {
+  switch s {
+    case kSeverityNone:
+      return "none"
+    case kSeverityWarning:
+      return "warning"
+    case kSeverityError:
+      return "error"
+  }
+  return ""
+}
+
+type ValidationResult struct {
+  etfName string // empty if unknown (and there will be a warning).
+  // The filing being validated.
+  cik int
+  seriesId string
+  filingDate string
+  warnings []string
+  errors []string
+  // Components for which we generated a synthetic identifier.
+  syntheticIds []IndexComponent
+}
+
+func (r *ValidationResult) addError(err string) {
+  r.errors = append(r.errors, err)
+}
+
+func (r *ValidationResult) addWarning(err string) {
+  r.warnings = append(r.warnings, err)
+}
+
+func (r ValidationResult) severity() Severity {
+  if len(r.errors) > 0 {
+    return kSeverityError
+  }
+  if len(r.warnings) > 0 {
+    return kSeverityWarning
+  }
+  return kSeverityNone
+}
+
+func (r ValidationResult) dump() {
+  hasErrors := len(r.errors) != 0
+  hasWarnings := len(r.warnings) != 0
+  if !hasErrors && !hasWarnings {
+    // No issue, nothing to report.
+    return
+  }
+  fmt.Printf("***************** Validation report for %s (filing_date=%s) *****************\n", r.etfName, r.filingDate)
+  if hasErrors {
+    fmt.Printf("Errors:\n")
+    for _, err := range r.errors {
+      fmt.Printf("  %s\n", err)
+    }
+    fmt.Printf("\n\n")
+  }
+  if hasWarnings {
+    fmt.Printf("Warnings:\n")
+    for _, warning := range r.warnings {
+      fmt.Printf("  %s\n", warning)
+    }
+    fmt.Printf("\n\n")
+  }
+}
+
+// RunSummary aggregates the validation results of a run.
+// It is dumped at the end of the run so that issues are not lost in the logs.
+type RunSummary struct {
+  results []ValidationResult
+}
+
+func (s *RunSummary) add(res ValidationResult) {
+  s.results = append(s.results, res)
+}
+
+func (s *RunSummary) worstSeverity() Severity {
+  worst := kSeverityNone
+  for _, res := range s.results {
+    worst = max(worst, res.severity())
+  }
+  return worst
+}
+
+func (s *RunSummary) dump() {
+  fmt.Printf("***************** Run summary *****************\n")
+  hasIssues := false
+  for _, res := range s.results {
+    if len(res.errors) == 0 && len(res.warnings) == 0 {
+      continue
+    }
+    hasIssues = true
+    etfName := res.etfName
+    if etfName == "" {
+      etfName = "<unknown>"
+    }
+    fmt.Printf("%s (filing_date=%s): %d error(s), %d warning(s)\n", etfName, res.filingDate, len(res.errors), len(res.warnings))
+    for _, component := range res.syntheticIds {
+      fmt.Printf("  synthetic identifier %s for name=%s\n", component.Id, component.Name)
+    }
+  }
+  if !hasIssues {
+    fmt.Printf("No issue found\n")
+  }
+  fmt.Printf("Worst severity: %s\n", s.worstSeverity())
+}
+
+// validateIndex runs the series and index rules of kValidationRules, as configured in validationConfig.
+func validateIndex(cik int, index Index) ValidationResult {
+  res := ValidationResult{"", cik, index.SeriesId, index.FilingDate, []string{}, []string{}, []IndexComponent{}}
+  etfName, known := seriesToEtfs[IndexId{cik, index.SeriesId}]
+  res.etfName = etfName
+  ctx := ruleContext{cik, etfName, known, index, Index{}}
+  runRules(&res, kSeriesStage, ctx)
+
+  // If the series has issues, it's usually not worth continuing.
+  // Doing so, ensure that we have an ETF name to report.
+  if (len(res.errors) > 0 || len(res.warnings) > 0) && !validationConfig.ContinueOnSeriesIssues {
+    return res
+  }
+
+  runRules(&res, kIndexStage, ctx)
+  for _, component := range index.Components {
+    if component.IdType == kSyntheticIdType {
+      res.syntheticIds = append(res.syntheticIds, component)
+    }
+  }
+  return res
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+  "fmt"
+  "os"
+  "path/filepath"
   "testing"
 )
 
@@ -9,6 +12,14 @@ const kValidSeriesId = "S000002841"
 const kInvalidSeriesId = "S123452841"
 const kDate = "2025-01-01"
 
+func TestMain(m *testing.M) {
+  // The validation looks up the ETFs in our map.
+  if err := initEtfs(); err != nil {
+    panic(fmt.Sprintf("Couldn't initialize the ETFs, err=%+v", err))
+  }
+  os.Exit(m.Run())
+}
+
 func TestValidate(t *testing.T) {
   tt := []struct {
     name string
@@ -17,18 +28,21 @@ func TestValidate(t *testing.T) {
     hasWarning bool
   } {
     // Valid.
-    {"Validate that cusip is known", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Advaxis Inc", "007624125", "cusip", 0.000000000181}}}, false, false},
+    {"Validate that cusip is known", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 0.000000000181}}}, false, false},
 
     // Invalid.
-    {"Validate the name of the index", Index{"", kValidSeriesId, kDate, []IndexComponent{}}, true, false},
-    {"Validate that the seriesId is known", Index{"Index", kInvalidSeriesId, kDate, []IndexComponent{}}, false, true},
-    {"Validate that the component have a name ", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"N/A", "JPY", "", 0.0039280644}}}, true, false},
-    {"Validate that the component have an ID", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Company", "", "ticker", 0.0039280644}}}, true, false},
-    {"Validate that N/A is not a valid ID", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Company", "N/A", "ticker", 0.0039280644}}}, true, false},
-    {"Validate that the component have a valid idType", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Company", "JPY", "", 0.0039280644}}}, true, false},
-    {"Validate that N/A is not a valid idType", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Company", "JPY", "N/A", 0.0039280644}}}, true, false},
-    {"Validate that the idType is known", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"Company", "JPY", "unknown", 0.0039280644}}}, false, true},
-    {"Validate that a component has a positive weight", Index{"Index", kValidSeriesId, kDate, []IndexComponent{IndexComponent{"BMC Medical Co Ltd","CNE100005WQ4", "", -0.0039280644}}}, true, false},
+    {"Validate the name of the index", Index{Name: "", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}, true, false},
+    {"Validate that the seriesId is known", Index{Name: "Index", SeriesId: kInvalidSeriesId, FilingDate: kDate, Components: []IndexComponent{}}, false, true},
+    {"Validate that the component have a name ", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "N/A", Id: "JPY", IdType: "", Weight: 0.0039280644}}}, true, false},
+    {"Validate that the component have an ID", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "", IdType: "ticker", Weight: 0.0039280644}}}, true, false},
+    {"Validate that N/A is not a valid ID", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "N/A", IdType: "ticker", Weight: 0.0039280644}}}, true, false},
+    {"Validate that the component have a valid idType", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "JPY", IdType: "", Weight: 0.0039280644}}}, true, false},
+    {"Validate that N/A is not a valid idType", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "JPY", IdType: "N/A", Weight: 0.0039280644}}}, true, false},
+    {"Validate that a synthetic identifier is reported", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "SYN0123456789ABCDEF", IdType: "synthetic", Weight: 0.0039280644}}}, false, true},
+    {"Validate the identifier's check digit", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Warby Parker Inc", Id: "US93403J1061", IdType: "isin", Weight: 0.0039280644}}}, false, true},
+    {"Validate that the components are not duplicated", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Barrick Mining Corp", Id: "CA06849F1080", IdType: "isin", Weight: 0.1}, IndexComponent{Name: "Barrick Mining Corp", Id: "CA06849F1080", IdType: "isin", Weight: 0.05}}}, false, true},
+    {"Validate that the idType is known", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "JPY", IdType: "unknown", Weight: 0.0039280644}}}, false, true},
+    {"Validate that a component has a positive weight", Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "BMC Medical Co Ltd", Id: "CNE100005WQ4", IdType: "", Weight: -0.0039280644}}}, true, false},
   }
 
   for _, tc := range tt {
@@ -49,3 +63,131 @@ func TestValidate(t *testing.T) {
     })
   }
 }
+
+func TestValidateIdentifier(t *testing.T) {
+  tt := []struct {
+    name string
+    id string
+    idType string
+    valid bool
+  } {
+    // Valid, from our data.
+    {"ISIN (Warby Parker Inc)", "US93403J1060", "isin", true},
+    {"ISIN (Eli Lilly & Co)", "US5324571083", "isin", true},
+    {"ISIN (BMC Medical Co Ltd)", "CNE100005WQ4", "isin", true},
+    {"ISIN (United States Treasury Strip Coupon)", "US912834PZ59", "isin", true},
+    {"CUSIP (Advaxis Inc)", "007624125", "cusip", true},
+    {"CUSIP with letters (ACG)", "ACG874152", "cusip", true},
+    {"CINS (Bayer CropScience Ltd/India)", "Y0761E135", "cins", true},
+    {"SEDOL (Novartis AG)", "9024056", "sedol", true},
+    {"SEDOL (Yango Group Co Ltd)", "9020548", "sedol", true},
+    {"Ticker (Viridian Therapeutics Inc)", "1843576D", "ticker", true},
+    {"Ticker with class", "BRK.B", "ticker", true},
+    {"FAID is not validated", "023CVR996", "faid", true},
+    {"VID is not validated", "V1046523401", "vid", true},
+
+    // Corrupted.
+    {"ISIN with wrong check digit", "US93403J1061", "isin", false},
+    {"ISIN with swapped digits", "US5324571038", "isin", false},
+    {"ISIN too short", "US93403J106", "isin", false},
+    {"ISIN without country", "0093403J1060", "isin", false},
+    {"CUSIP with wrong check digit", "007624126", "cusip", false},
+    {"CUSIP too long", "0076241250", "cusip", false},
+    {"CINS with wrong check digit", "Y0761E136", "cins", false},
+    {"SEDOL with wrong check digit", "9024057", "sedol", false},
+    {"SEDOL with a vowel", "9A24056", "sedol", false},
+    {"SEDOL without check digit (NII Holdings Inc)", "BYR4ESC", "sedol", false},
+    {"SEDOL too long", "N96ESC012", "sedol", false},
+    {"Ticker with space", "BRK B", "ticker", false},
+    {"Ticker in lowercase", "voo", "ticker", false},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      err := validateIdentifier(tc.id, tc.idType)
+      if tc.valid && err != nil {
+        t.Errorf("Expected %s (%s) to be valid but got err=%+v", tc.id, tc.idType, err)
+      }
+      if !tc.valid && err == nil {
+        t.Errorf("Expected %s (%s) to be malformed but got no error", tc.id, tc.idType)
+      }
+    })
+  }
+}
+
+func TestValidationConfig(t *testing.T) {
+  zeroWeight := Index{Name: "Index", SeriesId: kValidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Advaxis Inc", Id: "007624125", IdType: "cusip", Weight: 0}, IndexComponent{Name: "Novartis AG", Id: "9024056", IdType: "sedol", Weight: 99}}}
+  unknownSeries := Index{Name: "Index", SeriesId: kInvalidSeriesId, FilingDate: kDate, Components: []IndexComponent{IndexComponent{Name: "Company", Id: "JPY", IdType: "", Weight: 100}}}
+  tt := []struct {
+    name string
+    config ValidationConfig
+    index Index
+    expectedErrors int
+    expectedWarnings int
+  } {
+    {"Defaults", ValidationConfig{}, zeroWeight, 0, 0},
+    {"Enable a rule", ValidationConfig{Rules: map[string]RuleConfig{"zero_weight": RuleConfig{Severity: "warning"}}}, zeroWeight, 0, 1},
+    {"Override the severity per ETF", ValidationConfig{Rules: map[string]RuleConfig{"zero_weight": RuleConfig{Severity: "warning"}}, Etfs: map[string]map[string]RuleConfig{"VXF": map[string]RuleConfig{"zero_weight": RuleConfig{Severity: "error"}}}}, zeroWeight, 1, 0},
+    {"Disable a rule per ETF", ValidationConfig{Rules: map[string]RuleConfig{"zero_weight": RuleConfig{Severity: "warning"}}, Etfs: map[string]map[string]RuleConfig{"VXF": map[string]RuleConfig{"zero_weight": RuleConfig{Severity: "off"}}}}, zeroWeight, 0, 0},
+    {"Override a parameter only", ValidationConfig{Rules: map[string]RuleConfig{"weight_sum": RuleConfig{Severity: "error"}}, Etfs: map[string]map[string]RuleConfig{"VXF": map[string]RuleConfig{"weight_sum": RuleConfig{Min: param(99.5)}}}}, zeroWeight, 1, 0},
+    {"Override the known id types", ValidationConfig{Rules: map[string]RuleConfig{"unknown_id_type": RuleConfig{Values: []string{"isin"}}}}, zeroWeight, 0, 2},
+    {"Stop on series issues", ValidationConfig{}, unknownSeries, 0, 1},
+    {"Continue on series issues", ValidationConfig{ContinueOnSeriesIssues: true}, unknownSeries, 1, 1},
+    {"Unknown series as an error", ValidationConfig{Rules: map[string]RuleConfig{"unknown_series": RuleConfig{Severity: "error"}}}, unknownSeries, 1, 0},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      defer func(config ValidationConfig) { validationConfig = config }(validationConfig)
+      validationConfig = tc.config
+      res := validateIndex(kCompanyId, tc.index)
+      if len(res.errors) != tc.expectedErrors || len(res.warnings) != tc.expectedWarnings {
+        t.Errorf("Expected %d errors and %d warnings but got errors=%+v, warnings=%+v", tc.expectedErrors, tc.expectedWarnings, res.errors, res.warnings)
+      }
+    })
+  }
+}
+
+func TestLoadValidationConfig(t *testing.T) {
+  tt := []struct {
+    name string
+    content string
+    valid bool
+  } {
+    {"Valid", `{"rules": {"zero_weight": {"severity": "warning"}}, "etfs": {"EDV": {"weight_sum": {"max": 110}}}}`, true},
+    {"Unknown rule", `{"rules": {"zero_weights": {"severity": "warning"}}}`, false},
+    {"Unknown rule per ETF", `{"etfs": {"EDV": {"zero_weights": {"severity": "warning"}}}}`, false},
+    {"Unknown severity", `{"rules": {"zero_weight": {"severity": "fatal"}}}`, false},
+    {"Negative max", `{"rules": {"top_holding_disappeared": {"max": -1}}}`, false},
+    {"Fractional count", `{"rules": {"top_holding_disappeared": {"max": 2.5}}}`, false},
+    {"Fraction above 1", `{"rules": {"id_type_mix_change": {"max": 1.5}}}`, false},
+    {"Min above max", `{"rules": {"weight_sum": {"min": 101, "max": 99}}}`, false},
+    {"Min above the default max", `{"rules": {"weight_sum": {"min": 110}}}`, false},
+    {"Min above max per ETF", `{"rules": {"weight_sum": {"min": 100}}, "etfs": {"EDV": {"weight_sum": {"max": 99}}}}`, false},
+    {"Unused parameter", `{"rules": {"zero_weight": {"max": 1}}}`, false},
+    {"Unused values", `{"rules": {"weight_sum": {"values": ["isin"]}}}`, false},
+    {"Invalid JSON", `{"rules": [}`, false},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      defer func(config ValidationConfig) { validationConfig = config }(validationConfig)
+      path := filepath.Join(t.TempDir(), "validation_config.json")
+      if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+        t.Errorf("Failed to write the config (err=%+v)", err)
+        return
+      }
+      err := loadValidationConfig(path)
+      if tc.valid && err != nil {
+        t.Errorf("Expected a valid config but got err=%+v", err)
+      }
+      if !tc.valid && err == nil {
+        t.Errorf("Expected an invalid config but got no error")
+      }
+    })
+  }
+
+  if err := loadValidationConfig(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+    t.Errorf("Expected a missing config to keep the defaults but got err=%+v", err)
+  }
+}
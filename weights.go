@@ -0,0 +1,25 @@
+package main
+
+import (
+  "strings"
+)
+
+// Asset category of the short-term investment vehicles (e.g. money market funds), reported as cash.
+const kCashAssetCategory = "STIV"
+// Vanguard sweeps the funds' cash into its CMT funds (e.g. "Vanguard Cmt Funds-Vanguard Market Liquidity Fund"),
+// including the cash collateral of the securities lending.
+const kCashSweepNamePrefix = "Vanguard Cmt Funds"
+
+func isCash(component invstOrSec) bool {
+  return component.AssetCat == kCashAssetCategory || strings.HasPrefix(component.Name, kCashSweepNamePrefix)
+}
+
+func computeWeightTotals(index Index, derivativesWeight float64, cashWeight float64) *WeightTotals {
+  componentsWeight := totalWeight(index)
+  return &WeightTotals{
+    Components: float32(componentsWeight),
+    Derivatives: float32(derivativesWeight),
+    Cash: float32(cashWeight),
+    Residual: float32(100 - componentsWeight - derivativesWeight),
+  }
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+  "encoding/xml"
+  "fmt"
+  "strings"
+  "testing"
+)
+
+func TestWeightTotals(t *testing.T) {
+  stock := `<invstOrSec><name>Eli Lilly &amp; Co</name><identifiers><isin value="US5324571083"/></identifiers><pctVal>60</pctVal><assetCat>EC</assetCat></invstOrSec>`
+  cmt := `<invstOrSec><name>Vanguard Cmt Funds-Vanguard Market Liquidity Fund</name><identifiers><other otherDesc="FAID" value="CMT001142"/></identifiers><pctVal>1.5</pctVal></invstOrSec>`
+  moneyMarket := `<invstOrSec><name>Money Market Fund</name><identifiers><isin value="US0000000000"/></identifiers><pctVal>2</pctVal><assetCat>STIV</assetCat></invstOrSec>`
+  future := `<invstOrSec><name>N/A</name><identifiers><ticker value="RTYU5"/></identifiers><pctVal>0.5</pctVal><derivativeInfo><futrDeriv derivCat="FUT"></futrDeriv></derivativeInfo></invstOrSec>`
+  swap := `<invstOrSec><name>N/A</name><identifiers><other otherDesc="CONTRACT_VANGUARD_ID" value="V1047133201"/></identifiers><pctVal>-1.5</pctVal><derivativeInfo><swapDeriv derivCat="SWP"></swapDeriv></derivativeInfo></invstOrSec>`
+  tt := []struct {
+    name string
+    invstOrSecsXml string
+    expected WeightTotals
+    hasWarning bool
+  } {
+    {"Only components", stock + stock, WeightTotals{Components: 120, Derivatives: 0, Cash: 0, Residual: -20}, true},
+    {"Cash sweep", stock + cmt, WeightTotals{Components: 61.5, Derivatives: 0, Cash: 1.5, Residual: 38.5}, true},
+    {"Short-term investment vehicle", stock + moneyMarket, WeightTotals{Components: 62, Derivatives: 0, Cash: 2, Residual: 38}, true},
+    {"Derivatives", stock + future + swap, WeightTotals{Components: 60, Derivatives: -1, Cash: 0, Residual: 41}, true},
+    {"Reconciled", stock + cmt + moneyMarket + future + `<invstOrSec><name>Apple Inc</name><identifiers><isin value="US0378331005"/></identifiers><pctVal>35</pctVal></invstOrSec>`, WeightTotals{Components: 98.5, Derivatives: 0.5, Cash: 3.5, Residual: 1}, false},
+  }
+
+  for _, tc := range tt {
+    t.Run(tc.name, func (t *testing.T) {
+      payload := fmt.Sprintf(`<edgarSubmission><formData><genInfo><seriesName>VANGUARD EXTENDED MARKET INDEX FUND</seriesName><seriesId>%s</seriesId></genInfo><invstOrSecs>%s</invstOrSecs></formData></edgarSubmission>`, kValidSeriesId, tc.invstOrSecsXml)
+      submission := singleSubmission{}
+      if err := xml.Unmarshal([]byte(payload), &submission); err != nil {
+        panic(fmt.Sprintf("Failed to parse XML: %s (error=%+v).\n\nDid you make a mistake in the test?", payload, err))
+      }
+      index := populateIndexFromSingleSubmission(submission, SubmissionInfo{kCompanyId, kAccessionNumber, kSubmissionDate})
+      if index.Weights == nil {
+        t.Errorf("Expected weight totals but got none")
+        return
+      }
+      actual := *index.Weights
+      if !almostEqual(actual.Components, tc.expected.Components) || !almostEqual(actual.Derivatives, tc.expected.Derivatives) || !almostEqual(actual.Cash, tc.expected.Cash) || !almostEqual(actual.Residual, tc.expected.Residual) {
+        t.Errorf("Mismatched weight totals, expected=%+v but got=%+v", tc.expected, actual)
+        return
+      }
+
+      res := ValidationResult{etfName: "VXF", warnings: []string{}, errors: []string{}}
+      runRules(&res, kIndexStage, ruleContext{kCompanyId, "VXF", true, index, Index{}})
+      hasWarning := false
+      for _, warning := range res.warnings {
+        hasWarning = hasWarning || strings.HasPrefix(warning, "ETF VXF has a residual")
+      }
+      if hasWarning != tc.hasWarning {
+        t.Errorf("Expected a residual warning=%t but got warnings=%+v", tc.hasWarning, res.warnings)
+      }
+    })
+  }
+}